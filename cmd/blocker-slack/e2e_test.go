@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/blockerslack/config"
+	"github.com/openshift/bugzilla-tools/pkg/blockerslack/reporters/blockers"
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/eventlogger"
+	"github.com/openshift/bugzilla-tools/pkg/fakebugzilla"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+// TestMain serves --test-bug-data from a fake bugzilla
+func TestMain(m *testing.M) {
+	fakes := &fakebugzilla.TestClients{}
+	bugs.TestClient = fakes.Client
+	code := m.Run()
+	fakes.Close()
+	os.Exit(code)
+}
+
+// fakeSlack records every message instead of sending it
+type fakeSlack struct {
+	channels map[string][]string
+	emails   map[string][]string
+	debug    []string
+}
+
+func (f *fakeSlack) MessageChannel(channel, message string) error {
+	f.channels[channel] = append(f.channels[channel], message)
+	return nil
+}
+
+func (f *fakeSlack) MessageDebug(message string) error {
+	f.debug = append(f.debug, message)
+	return nil
+}
+
+func (f *fakeSlack) MessageEmail(email, message string) error {
+	f.emails[email] = append(f.emails[email], message)
+	return nil
+}
+
+func (f *fakeSlack) SetEmailMap(map[string]string) {}
+
+func TestEndToEnd(t *testing.T) {
+	orgData := teams.OrgData{
+		Teams: map[string]teams.TeamInfo{
+			"Networking": {Name: "Networking", Components: []string{"Networking"}, SlackChan: "#networking"},
+		},
+	}
+	orgSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewEncoder(w).Encode(orgData); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer orgSrv.Close()
+
+	cmd := &cobra.Command{}
+	bugs.AddFlags(cmd)
+	teams.AddFlags(cmd)
+	if err := cmd.Flags().Parse([]string{"--test-bug-data=testdata/bugs.yaml", "--org-data-url=" + orgSrv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	orgInfo, err := teams.GetOrgData(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bugData, err := bugs.GetBugData(cmd, orgInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slackClient := &fakeSlack{channels: map[string][]string{}, emails: map[string][]string{}}
+	recorder := eventlogger.NewRecorder("BlockerSlack")
+	c := blockers.NewBlockersReporter(nil, config.OperatorConfig{}, bugData, orgInfo, slackClient, recorder)
+	if err := c.Sync(context.Background(), factory.NewSyncContext("BlockersReporter", recorder)); err != nil {
+		t.Fatal(err)
+	}
+
+	team := strings.Join(slackClient.channels["#networking"], "\n")
+	for _, expected := range []string{"2 Bugs", "1 Release Blockers", "Bugs in \"POST\""} {
+		if !strings.Contains(team, expected) {
+			t.Errorf("expected the team report to contain %q, got:\n%s", expected, team)
+		}
+	}
+	personal := strings.Join(slackClient.emails["someone@example.com"], "\n")
+	if !strings.Contains(personal, "*release blockers*") {
+		t.Errorf("expected the assignee to be told about the blocker, got:\n%s", personal)
+	}
+	if len(slackClient.debug) != 1 || !strings.Contains(slackClient.debug[0], "Sent to team: Networking") {
+		t.Errorf("unexpected debug messages: %v", slackClient.debug)
+	}
+}
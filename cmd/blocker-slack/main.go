@@ -18,6 +18,7 @@ import (
 
 	"github.com/openshift/bugzilla-tools/pkg/blockerslack"
 	"github.com/openshift/bugzilla-tools/pkg/blockerslack/config"
+	"github.com/openshift/bugzilla-tools/pkg/blockerslack/reporters/lifecycle"
 	"github.com/openshift/bugzilla-tools/pkg/bugs"
 	"github.com/openshift/bugzilla-tools/pkg/slack"
 	"github.com/openshift/bugzilla-tools/pkg/teams"
@@ -53,6 +54,7 @@ func main() {
 	slack.AddFlags(cmd)
 	bugs.AddFlags(cmd)
 	teams.AddFlags(cmd)
+	lifecycle.AddFlags(cmd)
 	cmd.Flags().Bool("debug", false, "Run in debug mode sending all messages to the debug channel")
 
 	if v := version.Get().String(); len(v) == 0 {
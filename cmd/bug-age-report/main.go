@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+const (
+	percentilesFlagName = "percentiles"
+	oldestFlagName      = "oldest"
+)
+
+func days(d time.Duration) string {
+	return fmt.Sprintf("%.1f", d.Hours()/24)
+}
+
+func doMain(cmd *cobra.Command, _ []string) error {
+	orgData, err := teams.GetOrgData(cmd)
+	if err != nil {
+		return err
+	}
+	bugData, err := bugs.GetReconciledBugData(cmd, orgData)
+	if err != nil {
+		return err
+	}
+	percentiles, err := cmd.Flags().GetFloat64Slice(percentilesFlagName)
+	if err != nil {
+		return err
+	}
+	for _, p := range percentiles {
+		if p <= 0 || p > 1 {
+			return fmt.Errorf("--%s must be between 0 and 1, got %v", percentilesFlagName, p)
+		}
+	}
+	oldest, err := cmd.Flags().GetInt(oldestFlagName)
+	if err != nil {
+		return err
+	}
+
+	ages, err := bugData.GetAges(time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Some bugs are left out: %v\n", err)
+	}
+	byTeam := bugs.TeamAgePercentiles(ages, percentiles)
+	teamNames := make([]string, 0, len(byTeam))
+	for team := range byTeam {
+		teamNames = append(teamNames, team)
+	}
+	sort.Strings(teamNames)
+
+	header := []string{"team", "bugs"}
+	for _, kind := range []string{"age", "in_status", "since_triage"} {
+		for _, p := range percentiles {
+			header = append(header, fmt.Sprintf("%s_p%v_days", kind, p*100))
+		}
+	}
+	fmt.Println(strings.Join(header, ","))
+	for _, team := range teamNames {
+		result := byTeam[team]
+		line := []string{team, fmt.Sprintf("%d", result.Count)}
+		for _, values := range []map[float64]time.Duration{result.Age, result.InStatus, result.SinceTriage} {
+			for _, p := range percentiles {
+				line = append(line, days(values[p]))
+			}
+		}
+		fmt.Println(strings.Join(line, ","))
+	}
+
+	if oldest > 0 {
+		sort.Slice(ages, func(i, j int) bool { return ages[i].SinceTriage > ages[j].SinceTriage })
+		if oldest > len(ages) {
+			oldest = len(ages)
+		}
+		fmt.Println()
+		fmt.Println("id,team,age_days,in_status_days,since_triage_days")
+		for _, age := range ages[:oldest] {
+			fmt.Printf("%d,%s,%s,%s,%s\n", age.ID, age.Team, days(age.Age), days(age.InStatus), days(age.SinceTriage))
+		}
+	}
+	return nil
+}
+
+func main() {
+	cmd := &cobra.Command{
+		Use:   filepath.Base(os.Args[0]),
+		Short: "Report percentiles of how old, how long in their status and how long untriaged every team's bugs are",
+		RunE:  doMain,
+	}
+	bugs.AddFlags(cmd)
+	teams.AddFlags(cmd)
+	cmd.Flags().Float64Slice(percentilesFlagName, []float64{0.5, 0.9, 1}, "Percentiles to report, between 0 and 1")
+	cmd.Flags().Int(oldestFlagName, 0, "Also list this many bugs which went the longest without being triaged")
+	cmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eparis/bugzilla"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/bugzilla-tools/pkg/api"
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+)
+
+const (
+	auditDirFlagName = "audit-dir"
+	runIDFormat      = "20060102T150405.000Z"
+)
+
+// auditRecord describes a single update made to a single bug. Changes holds
+// the value of every touched field before and after the update.
+type auditRecord struct {
+	Run     string        `json:"run"`
+	Action  string        `json:"action"`
+	ID      int           `json:"id"`
+	Time    time.Time     `json:"time"`
+	Changes []fieldChange `json:"changes"`
+}
+
+// auditLog is an append-only file of auditRecords, one JSON object per line.
+// Every run writes to its own file named after the run ID.
+type auditLog struct {
+	sync.Mutex
+	run  string
+	file *os.File
+}
+
+func auditPath(dir, run string) string {
+	return filepath.Join(dir, run+".jsonl")
+}
+
+// runNameRegexp matches what may not appear in the name part of a run ID
+var runNameRegexp = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// newRunID returns a unique ID for a run named name, like the action a daemon
+// runs. It starts with the time so run IDs sort in the order they started,
+// and ends with a random suffix so runs started at the same time never share
+// an audit log.
+func newRunID(name string) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("unable to generate a run ID: %v", err)
+	}
+	return fmt.Sprintf("%s-%s-%s", time.Now().UTC().Format(runIDFormat), runNameRegexp.ReplaceAllString(name, "_"), hex.EncodeToString(suffix)), nil
+}
+
+// newAuditLog opens the audit log of a new run named name.
+func newAuditLog(dir, name string) (*auditLog, error) {
+	run, err := newRunID(name)
+	if err != nil {
+		return nil, err
+	}
+	return openAuditLog(dir, run)
+}
+
+func openAuditLog(dir, run string) (*auditLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// O_EXCL so two runs can never write, and so roll back, the same log
+	f, err := os.OpenFile(auditPath(dir, run), os.O_APPEND|os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{
+		run:  run,
+		file: f,
+	}, nil
+}
+
+func (a *auditLog) Record(action string, id int, changes []fieldChange) error {
+	record := auditRecord{
+		Run:     a.run,
+		Action:  action,
+		ID:      id,
+		Time:    time.Now().UTC(),
+		Changes: changes,
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	a.Lock()
+	defer a.Unlock()
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return a.file.Sync()
+}
+
+func (a *auditLog) Close() error {
+	return a.file.Close()
+}
+
+func readAuditLog(dir, run string) ([]auditRecord, error) {
+	f, err := os.Open(auditPath(dir, run))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records := []auditRecord{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		record := auditRecord{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+func splitKeywords(in string) []string {
+	if in == "" {
+		return nil
+	}
+	return strings.Split(in, ",")
+}
+
+// applyUpdate updates a single bug and records the previous value of every
+// field the update touched in the audit log. If the bug fails the
+// preconditions it is not updated and the reason is returned.
+func applyUpdate(client bugzilla.Client, audit *auditLog, action string, id int, update bugzilla.BugUpdate, pre *api.BugPreconditions) (string, error) {
+	bug, err := client.GetBug(id)
+	if err != nil {
+		return "", err
+	}
+	if reason := skipReason(pre, bug, time.Now()); reason != "" {
+		return reason, nil
+	}
+	logrus.Infof("Updating %d", id)
+	changes := diffBug(bug, update)
+	if err := client.UpdateBug(id, update); err != nil {
+		return "", err
+	}
+	return "", audit.Record(action, id, changes)
+}
+
+// currentValue is the value of a field, as recorded in a fieldChange, the bug
+// holds now.
+func currentValue(bug *bugzilla.Bug, field string) string {
+	switch {
+	case field == "status":
+		return bug.Status
+	case field == "resolution":
+		return bug.Resolution
+	case field == "target_release":
+		return firstOrEmpty(bug.TargetRelease)
+	case field == "priority":
+		return bug.Priority
+	case field == "severity":
+		return bug.Severity
+	case field == "assigned_to":
+		return bug.AssignedTo
+	case field == "whiteboard":
+		return bug.Whiteboard
+	case field == "cf_devel_whiteboard":
+		return bug.DevelWhiteboard
+	case field == "keywords":
+		return strings.Join(sets.NewString(bug.Keywords...).List(), ",")
+	case strings.HasPrefix(field, "flag:"):
+		return flagStatus(bug, strings.TrimPrefix(field, "flag:"))
+	}
+	return ""
+}
+
+// stillApplied splits the changes of record into those the bug still holds,
+// which can be rolled back, and those which were changed again since.
+func stillApplied(bug *bugzilla.Bug, record auditRecord) (applied, changedSince []fieldChange) {
+	for _, change := range record.Changes {
+		if currentValue(bug, change.Field) == change.New {
+			applied = append(applied, change)
+		} else {
+			changedSince = append(changedSince, change)
+		}
+	}
+	return applied, changedSince
+}
+
+// rollbackRecord undoes the changes of record which the bug still holds, so
+// edits made after the run are never overwritten. The fields which were left
+// alone are returned.
+func rollbackRecord(client bugzilla.Client, audit *auditLog, record auditRecord) ([]fieldChange, error) {
+	bug, err := client.GetBug(record.ID)
+	if err != nil {
+		return nil, err
+	}
+	applied, changedSince := stillApplied(bug, record)
+	if len(applied) == 0 {
+		return changedSince, nil
+	}
+	record.Changes = applied
+	update := inverseUpdate(record)
+	logrus.Infof("Rolling back %d", record.ID)
+	changes := diffBug(bug, update)
+	if err := client.UpdateBug(record.ID, update); err != nil {
+		return nil, err
+	}
+	return changedSince, audit.Record("rollback:"+record.Action, record.ID, changes)
+}
+
+// inverseUpdate returns the update which puts every field in changes back to
+// its previous value. Comments can not be removed from a bug, so a private
+// comment explaining the rollback is added instead.
+func inverseUpdate(record auditRecord) bugzilla.BugUpdate {
+	update := bugzilla.BugUpdate{}
+	for _, change := range record.Changes {
+		switch {
+		case change.Field == "status":
+			update.Status = change.Old
+		case change.Field == "resolution":
+			update.Resolution = change.Old
+		case change.Field == "target_release":
+			update.TargetRelease = change.Old
+			if update.TargetRelease == "" {
+				update.TargetRelease = "---"
+			}
+		case change.Field == "priority":
+			update.Priority = change.Old
+		case change.Field == "severity":
+			update.Severity = change.Old
+		case change.Field == "assigned_to":
+			update.AssignedTo = change.Old
+		case change.Field == "whiteboard":
+			update.Whiteboard = change.Old
+		case change.Field == "cf_devel_whiteboard":
+			update.DevWhiteboard = change.Old
+		case change.Field == "keywords":
+			old := sets.NewString(splitKeywords(change.Old)...)
+			new := sets.NewString(splitKeywords(change.New)...)
+			update.Keywords = &bugzilla.BugKeywords{
+				Add:    old.Difference(new).List(),
+				Remove: new.Difference(old).List(),
+			}
+		case strings.HasPrefix(change.Field, "flag:"):
+			status := change.Old
+			if status == "" {
+				status = flagClear
+			}
+			update.Flags = append(update.Flags, bugzilla.FlagChange{
+				Name:   strings.TrimPrefix(change.Field, "flag:"),
+				Status: status,
+			})
+		}
+	}
+	update.Comment = &bugzilla.BugComment{
+		Private: true,
+		Body:    fmt.Sprintf("Reverting the automated %q update made by run %s.", record.Action, record.Run),
+	}
+	return update
+}
+
+func doRollback(cmd *cobra.Command) error {
+	run, err := cmd.Flags().GetString("run")
+	if err != nil {
+		return err
+	}
+	if run == "" {
+		return fmt.Errorf("--run must be set to the ID of the run to roll back")
+	}
+	auditDir, err := cmd.Flags().GetString(auditDirFlagName)
+	if err != nil {
+		return err
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	records, err := readAuditLog(auditDir, run)
+	if err != nil {
+		return err
+	}
+
+	// Undo the newest change to each bug first, in case several actions in
+	// the run touched the same field.
+	byBug := map[int][]auditRecord{}
+	ids := []int{}
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if _, ok := byBug[record.ID]; !ok {
+			ids = append(ids, record.ID)
+		}
+		byBug[record.ID] = append(byBug[record.ID], record)
+	}
+
+	if dryRun {
+		diffs := []bugDiff{}
+		for _, id := range ids {
+			for _, record := range byBug[id] {
+				changes := []fieldChange{}
+				for _, change := range record.Changes {
+					changes = append(changes, fieldChange{Field: change.Field, Old: change.New, New: change.Old})
+				}
+				diffs = append(diffs, bugDiff{Action: "rollback:" + record.Action, ID: id, Changes: changes})
+			}
+		}
+		return writeDiffs(os.Stdout, outputTable, diffs)
+	}
+
+	client, err := bugs.BugzillaClient(cmd)
+	if err != nil {
+		return err
+	}
+	eng, err := newEngine(cmd)
+	if err != nil {
+		return err
+	}
+	audit, err := newAuditLog(auditDir, "rollback")
+	if err != nil {
+		return err
+	}
+	defer audit.Close()
+
+	client = eng.limit(client)
+	var lock sync.Mutex
+	skipped := 0
+	failed := eng.run(ids, func(id int) error {
+		for _, record := range byBug[id] {
+			changedSince, err := rollbackRecord(client, audit, record)
+			if err != nil {
+				return err
+			}
+			for _, change := range changedSince {
+				logrus.Warnf("Not rolling back %s of %d, it was changed from %q since the run", change.Field, id, change.New)
+			}
+			lock.Lock()
+			skipped += len(changedSince)
+			lock.Unlock()
+		}
+		return nil
+	})
+	if skipped > 0 {
+		logrus.Warnf("Left %d fields changed since run %s as they are", skipped, run)
+	}
+	errs := []error{}
+	for id, err := range failed {
+		errs = append(errs, fmt.Errorf("unable to roll back %d: %v", id, err))
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func newRollbackCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Undo every change made by a previous run using its audit log",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return doRollback(cmd)
+		},
+	}
+	cmd.Flags().String("run", "", "ID of the run to roll back, this is the name of the file in the audit directory")
+	cmd.Flags().Bool("dry-run", false, "Do not update any bugs, instead print the changes the rollback would make")
+	addAuditFlags(cmd)
+	addEngineFlags(cmd)
+	bugs.AddFlags(cmd)
+	return cmd
+}
+
+func addAuditFlags(cmd *cobra.Command) {
+	cmd.Flags().String(auditDirFlagName, "audit", "Directory holding one audit log per run of every bug which was changed")
+}
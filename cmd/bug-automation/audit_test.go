@@ -0,0 +1,113 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eparis/bugzilla"
+
+	"github.com/openshift/bugzilla-tools/pkg/fakebugzilla"
+)
+
+func TestInverseUpdateRestoresBug(t *testing.T) {
+	bug := &bugzilla.Bug{
+		ID:            1,
+		TargetRelease: []string{"4.5.0"},
+		Keywords:      []string{"UpcomingSprint"},
+		Flags:         []bugzilla.Flag{{Name: "reviewed-in-sprint", Status: "+"}},
+	}
+	update := bugzilla.BugUpdate{
+		TargetRelease: "---",
+		Keywords:      &bugzilla.BugKeywords{Remove: []string{"UpcomingSprint"}},
+		Flags: []bugzilla.FlagChange{
+			{Name: "reviewed-in-sprint", Status: "-"},
+			{Name: "blocker", Status: "?"},
+		},
+		Comment: &bugzilla.BugComment{Body: "automated"},
+	}
+	record := auditRecord{Run: "run", Action: "test", ID: 1, Changes: diffBug(bug, update)}
+
+	inverse := inverseUpdate(record)
+	if inverse.TargetRelease != "4.5.0" {
+		t.Errorf("expected target release 4.5.0, got %q", inverse.TargetRelease)
+	}
+	expectedKeywords := &bugzilla.BugKeywords{Add: []string{"UpcomingSprint"}, Remove: []string{}}
+	if !reflect.DeepEqual(inverse.Keywords, expectedKeywords) {
+		t.Errorf("expected keywords %#v, got %#v", expectedKeywords, inverse.Keywords)
+	}
+	expectedFlags := []bugzilla.FlagChange{
+		{Name: "reviewed-in-sprint", Status: "+"},
+		{Name: "blocker", Status: "X"},
+	}
+	if !reflect.DeepEqual(inverse.Flags, expectedFlags) {
+		t.Errorf("expected flags %#v, got %#v", expectedFlags, inverse.Flags)
+	}
+	if inverse.Comment == nil || !inverse.Comment.Private {
+		t.Errorf("expected a private rollback comment, got %#v", inverse.Comment)
+	}
+}
+
+func TestRunIDsNeverShareALog(t *testing.T) {
+	dir := t.TempDir()
+	first, err := newRunID("needs blocker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newRunID("needs blocker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatalf("expected runs started together to get different IDs, got %s twice", first)
+	}
+	if !strings.Contains(first, "-needs_blocker-") {
+		t.Errorf("expected the action name in %s", first)
+	}
+	audit, err := openAuditLog(dir, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+	if _, err := openAuditLog(dir, first); err == nil {
+		t.Errorf("expected a second run with the same ID to be refused")
+	}
+}
+
+func TestRollbackKeepsLaterEdits(t *testing.T) {
+	bz := fakebugzilla.New(&fakebugzilla.Fixture{Bugs: []bugzilla.Bug{
+		{ID: 1, Status: "NEW", Priority: "high", Severity: "high", TargetRelease: []string{"4.6.0"}},
+	}})
+	defer bz.Close()
+	client := bz.Client()
+	audit, err := newAuditLog(t.TempDir(), "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+
+	update := bugzilla.BugUpdate{TargetRelease: "---", Priority: "low"}
+	if _, err := applyUpdate(client, audit, "test", 1, update, nil); err != nil {
+		t.Fatal(err)
+	}
+	// someone triages the bug again after the run
+	if err := client.UpdateBug(1, bugzilla.BugUpdate{Priority: "urgent"}); err != nil {
+		t.Fatal(err)
+	}
+
+	record := auditRecord{Run: audit.run, Action: "test", ID: 1, Changes: []fieldChange{
+		{Field: "target_release", Old: "4.6.0", New: "---"},
+		{Field: "priority", Old: "high", New: "low"},
+	}}
+	changedSince, err := rollbackRecord(client, audit, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changedSince) != 1 || changedSince[0].Field != "priority" {
+		t.Errorf("expected only the priority to be left alone, got %v", changedSince)
+	}
+	bug := bz.Bug(1)
+	if !reflect.DeepEqual(bug.TargetRelease, []string{"4.6.0"}) || bug.Priority != "urgent" {
+		t.Errorf("expected the target release rolled back and the later priority kept, got %v %s", bug.TargetRelease, bug.Priority)
+	}
+}
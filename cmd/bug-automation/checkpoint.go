@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	checkpointFlagName = "checkpoint"
+)
+
+// checkpoint records which bugs each action has already updated so an
+// interrupted run can be resumed without touching those bugs again. An action's
+// entries are cleared once it completes without any failures.
+//
+// On disk it is one JSON line per updated bug, so recording a bug only appends
+// to the file instead of rewriting it.
+type checkpoint struct {
+	sync.Mutex
+	path string
+	done map[string]sets.Int
+	// dirSynced is true once the file is known to be in its directory
+	dirSynced bool
+}
+
+// checkpointEntry is a line of the checkpoint file
+type checkpointEntry struct {
+	Action string `json:"action"`
+	ID     int    `json:"id"`
+}
+
+// loadCheckpoint reads the checkpoint at path. An empty path returns a
+// checkpoint which is never written to disk.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	cp := &checkpoint{
+		path: path,
+		done: map[string]sets.Int{},
+	}
+	if path == "" {
+		return cp, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	var bad error
+	for line := 1; scanner.Scan(); line++ {
+		// Only the last line can be cut short by a crash, anything bad
+		// before it means the file is not a checkpoint
+		if bad != nil {
+			return nil, bad
+		}
+		entry := checkpointEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			bad = fmt.Errorf("%s:%d: %v", path, line, err)
+			continue
+		}
+		cp.add(entry.Action, entry.ID)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if bad != nil {
+		// drop the partial line, new entries would be appended to it
+		if err := cp.save(); err != nil {
+			return nil, err
+		}
+	}
+	return cp, nil
+}
+
+func (cp *checkpoint) add(action string, id int) {
+	if cp.done[action] == nil {
+		cp.done[action] = sets.NewInt()
+	}
+	cp.done[action].Insert(id)
+}
+
+func (cp *checkpoint) Has(action string, id int) bool {
+	cp.Lock()
+	defer cp.Unlock()
+	return cp.done[action].Has(id)
+}
+
+func (cp *checkpoint) Count(action string) int {
+	cp.Lock()
+	defer cp.Unlock()
+	return cp.done[action].Len()
+}
+
+// Record appends the bug to the checkpoint, and syncs it to disk before
+// returning.
+func (cp *checkpoint) Record(action string, id int) error {
+	cp.Lock()
+	defer cp.Unlock()
+	cp.add(action, id)
+	if cp.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(checkpointEntry{Action: action, ID: id})
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(cp.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if !cp.dirSynced {
+		// the first Record may have created the file
+		if err := syncDir(filepath.Dir(cp.path)); err != nil {
+			return err
+		}
+		cp.dirSynced = true
+	}
+	return nil
+}
+
+func (cp *checkpoint) Clear(action string) error {
+	cp.Lock()
+	defer cp.Unlock()
+	delete(cp.done, action)
+	return cp.save()
+}
+
+// save rewrites the whole checkpoint and must be called with the lock held.
+// The file is synced and then atomically replaced, so a crash never leaves a
+// partially written checkpoint behind.
+func (cp *checkpoint) save() error {
+	if cp.path == "" {
+		return nil
+	}
+	dir := filepath.Dir(cp.path)
+	tmp, err := ioutil.TempFile(dir, filepath.Base(cp.path))
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for action, ids := range cp.done {
+		for _, id := range ids.List() {
+			data, err := json.Marshal(checkpointEntry{Action: action, ID: id})
+			if err != nil {
+				return fail(err)
+			}
+			w.Write(append(data, '\n'))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), cp.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := syncDir(dir); err != nil {
+		return err
+	}
+	cp.dirSynced = true
+	return nil
+}
+
+// syncDir makes a rename in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint.jsonl")
+
+	cp, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{1, 2} {
+		if err := cp.Record("close", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cp.Record("assign", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash while appending leaves a partial last line
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"action":"close","i`)
+	f.Close()
+
+	cp, err = loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.Record("close", 4); err != nil {
+		t.Fatal(err)
+	}
+	cp, err = loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cp.Has("close", 1) || !cp.Has("close", 4) || !cp.Has("assign", 3) || cp.Count("close") != 3 {
+		t.Errorf("expected the recorded bugs, got %v", cp.done)
+	}
+
+	if err := cp.Clear("close"); err != nil {
+		t.Fatal(err)
+	}
+	cp, err = loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Count("close") != 0 || !cp.Has("assign", 3) {
+		t.Errorf("expected only close to be cleared, got %v", cp.done)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("garbage\n{\"action\":\"close\",\"id\":1}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCheckpoint(path); err == nil {
+		t.Errorf("expected a corrupt checkpoint to be rejected")
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eparis/bugzilla"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/robfig/cron"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/openshift/bugzilla-tools/pkg/api"
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/eventlogger"
+	"github.com/openshift/bugzilla-tools/pkg/httpserver"
+	"github.com/openshift/bugzilla-tools/pkg/slack"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+const (
+	defaultScheduleFlagName = "default-schedule"
+	reloadIntervalFlagName  = "reload-interval"
+	slackChannelFlagName    = "slack-channel"
+)
+
+var (
+	matchedBugs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bug_automation_matched_bugs_total",
+		Help: "Bugs returned by an action's query",
+	}, []string{"action"})
+	updatedBugs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bug_automation_updated_bugs_total",
+		Help: "Bugs successfully updated by an action",
+	}, []string{"action"})
+	failedBugs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bug_automation_failed_bugs_total",
+		Help: "Bugs an action failed to update",
+	}, []string{"action"})
+)
+
+// daemon runs every action on its own cron schedule. Each action gets its own
+// factory.Controller, and all of them are replaced whenever the loaded actions
+// change.
+type daemon struct {
+	cmd             *cobra.Command
+	client          bugzilla.Client
+	eng             *engine
+	auditDir        string
+	defaultSchedule string
+	recorder        events.Recorder
+
+	// ctx is the lifetime of the daemon, the action controllers are run
+	// with a child of it so they can be stopped on reload.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// lock protects everything below
+	lock   sync.Mutex
+	loaded string
+	// actionLocks make sure an action is never run twice at once, even
+	// while an old controller is finishing its run after a reload. Different
+	// actions run at the same time.
+	actionLocks map[string]*sync.Mutex
+	// running are the runs in flight, stopping is set once no new run may
+	// start
+	running  sync.WaitGroup
+	stopping bool
+}
+
+// actionLock returns the lock of the action named name.
+func (d *daemon) actionLock(name string) *sync.Mutex {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.actionLocks == nil {
+		d.actionLocks = map[string]*sync.Mutex{}
+	}
+	if d.actionLocks[name] == nil {
+		d.actionLocks[name] = &sync.Mutex{}
+	}
+	return d.actionLocks[name]
+}
+
+// startRun is false once the daemon is stopping, otherwise the run must call
+// d.running.Done when it finishes.
+func (d *daemon) startRun() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.stopping {
+		return false
+	}
+	d.running.Add(1)
+	return true
+}
+
+// stop stops every action controller and waits for the runs in flight to
+// finish updating their bugs.
+func (d *daemon) stop() {
+	d.lock.Lock()
+	d.stopping = true
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.lock.Unlock()
+	d.running.Wait()
+}
+
+// isLoaded is true once the actions were loaded.
+func (d *daemon) isLoaded() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.loaded != ""
+}
+
+func (d *daemon) schedule(action api.BugAction) []string {
+	if len(action.Schedule) > 0 {
+		return action.Schedule
+	}
+	if action.Default && d.defaultSchedule != "" {
+		return []string{d.defaultSchedule}
+	}
+	return nil
+}
+
+// reload reads the actions again and restarts the action controllers if they
+// changed. If the new actions can not be loaded the old ones keep running.
+func (d *daemon) reload(ctx context.Context, syncCtx factory.SyncContext) error {
+	actions, err := getBugActions(d.cmd)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(actions)
+	if err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if string(data) == d.loaded || d.stopping {
+		return nil
+	}
+
+	controllers := []factory.Controller{}
+	for _, action := range actions {
+		schedule := d.schedule(action)
+		if len(schedule) == 0 {
+			logrus.Infof("%s has no schedule and will not be run", action.Name)
+			continue
+		}
+		for _, s := range schedule {
+			if _, err := cron.ParseStandard(s); err != nil {
+				return fmt.Errorf("%s: invalid schedule %q: %v", action.Name, s, err)
+			}
+		}
+		c := factory.New().WithSync(d.actionSync(action)).ResyncSchedule(schedule...).ToController("BugAutomation-"+action.Name, d.recorder)
+		controllers = append(controllers, c)
+	}
+
+	if d.cancel != nil {
+		d.cancel()
+	}
+	runCtx, cancel := context.WithCancel(d.ctx)
+	d.cancel = cancel
+	for _, c := range controllers {
+		go c.Run(runCtx, 1)
+	}
+	d.loaded = string(data)
+	d.recorder.Eventf("ActionsLoaded", "Scheduled %d of %d actions", len(controllers), len(actions))
+	return nil
+}
+
+// actionSync runs a single action, records it in its own audit log and
+// reports the result as metrics and an event. Only a failed query is returned
+// as an error. Failing to update some bugs, or matching more than maxBugs, is
+// only reported: an error makes the controller retry within milliseconds,
+// outside the action's schedule.
+func (d *daemon) actionSync(action api.BugAction) factory.SyncFunc {
+	return func(ctx context.Context, syncCtx factory.SyncContext) error {
+		if !d.startRun() {
+			return nil
+		}
+		defer d.running.Done()
+		lock := d.actionLock(action.Name)
+		lock.Lock()
+		defer lock.Unlock()
+
+		cp, err := loadCheckpoint("")
+		if err != nil {
+			return err
+		}
+		audit, err := newAuditLog(d.auditDir, action.Name)
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
+
+		r := &runner{
+			client: d.client,
+			eng:    d.eng,
+			cp:     cp,
+			audit:  audit,
+		}
+		result, err := r.runAction(action)
+		if err != nil {
+			d.recorder.Warningf("ActionFailed", "%s: %v", action.Name, err)
+			return err
+		}
+		matchedBugs.WithLabelValues(action.Name).Add(float64(result.matched))
+		updatedBugs.WithLabelValues(action.Name).Add(float64(result.updated))
+		failedBugs.WithLabelValues(action.Name).Add(float64(result.failed))
+
+		summary := fmt.Sprintf("%s matched %d bugs, updated %d, skipped %d, failed %d (run %s)", action.Name, result.matched, result.updated, result.skipped, result.failed, audit.run)
+		if len(result.errs) > 0 {
+			d.recorder.Warningf("ActionRun", "%s\n%v", summary, utilerrors.NewAggregate(result.errs))
+		} else {
+			d.recorder.Event("ActionRun", summary)
+		}
+		return nil
+	}
+}
+
+func getRecorder(cmd *cobra.Command, ctx context.Context) (events.Recorder, error) {
+	channel, err := cmd.Flags().GetString(slackChannelFlagName)
+	if err != nil {
+		return nil, err
+	}
+	if channel == "" {
+		return eventlogger.NewRecorder("BugAutomation"), nil
+	}
+	slackClient, err := slack.NewChannelClient(cmd, ctx, channel, false)
+	if err != nil {
+		return nil, err
+	}
+	return slack.NewRecorder(slackClient, "BugAutomation"), nil
+}
+
+func doDaemon(cmd *cobra.Command) error {
+	errs := make(chan error, 1)
+
+	server, err := httpserver.New(cmd)
+	if err != nil {
+		return err
+	}
+	server.Start(errs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, err := bugs.BugzillaClient(cmd)
+	if err != nil {
+		return err
+	}
+	eng, err := newEngine(cmd)
+	if err != nil {
+		return err
+	}
+	auditDir, err := cmd.Flags().GetString(auditDirFlagName)
+	if err != nil {
+		return err
+	}
+	defaultSchedule, err := cmd.Flags().GetString(defaultScheduleFlagName)
+	if err != nil {
+		return err
+	}
+	if defaultSchedule != "" {
+		if _, err := cron.ParseStandard(defaultSchedule); err != nil {
+			return fmt.Errorf("invalid --%s %q: %v", defaultScheduleFlagName, defaultSchedule, err)
+		}
+	}
+	reloadInterval, err := cmd.Flags().GetDuration(reloadIntervalFlagName)
+	if err != nil {
+		return err
+	}
+	recorder, err := getRecorder(cmd, ctx)
+	if err != nil {
+		return err
+	}
+
+	d := &daemon{
+		cmd:             cmd,
+		client:          eng.limit(client),
+		eng:             eng,
+		auditDir:        auditDir,
+		defaultSchedule: defaultSchedule,
+		recorder:        recorder,
+		ctx:             ctx,
+	}
+	reloader := factory.New().WithSync(d.reload).ResyncEvery(reloadInterval).ToController("BugAutomationReloader", recorder)
+	go reloader.Run(ctx, 1)
+	server.ReadyWhen("actions", d.isLoaded)
+
+	err = server.Wait(errs)
+	logrus.Info("Waiting for running actions to finish...")
+	d.stop()
+	return err
+}
+
+func newDaemonCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Keep running and run every action on its own cron schedule",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return doDaemon(cmd)
+		},
+	}
+	cmd.Flags().String("rules", "", "Load actions from the templated rules in this directory instead of operations/")
+	cmd.Flags().String(defaultScheduleFlagName, "", "Cron schedule for default actions which do not set their own, unset only runs actions with a schedule")
+	cmd.Flags().Duration(reloadIntervalFlagName, time.Minute, "How often to check the actions for changes")
+	cmd.Flags().String(slackChannelFlagName, "", "Slack channel to post a summary of every run to, unset only logs them")
+	addAuditFlags(cmd)
+	addEngineFlags(cmd)
+	bugs.AddFlags(cmd)
+	teams.AddFlags(cmd)
+	slack.AddFlags(cmd)
+	httpserver.AddFlags(cmd, ":8003")
+	return cmd
+}
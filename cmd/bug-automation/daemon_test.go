@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/eparis/bugzilla"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"golang.org/x/time/rate"
+
+	"github.com/openshift/bugzilla-tools/pkg/api"
+	"github.com/openshift/bugzilla-tools/pkg/fakebugzilla"
+)
+
+func TestDaemonSchedule(t *testing.T) {
+	tests := []struct {
+		name            string
+		action          api.BugAction
+		defaultSchedule string
+		expected        []string
+	}{
+		{
+			name:            "own schedule",
+			action:          api.BugAction{Default: true, Schedule: []string{"0 * * * *"}},
+			defaultSchedule: "@daily",
+			expected:        []string{"0 * * * *"},
+		},
+		{
+			name:            "default action",
+			action:          api.BugAction{Default: true},
+			defaultSchedule: "@daily",
+			expected:        []string{"@daily"},
+		},
+		{
+			name:     "default action without default schedule",
+			action:   api.BugAction{Default: true},
+			expected: nil,
+		},
+		{
+			name:            "non-default action",
+			action:          api.BugAction{},
+			defaultSchedule: "@daily",
+			expected:        nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := &daemon{defaultSchedule: test.defaultSchedule}
+			if got := d.schedule(test.action); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestActionSyncOnlyFailsOnQueryErrors(t *testing.T) {
+	bz := fakebugzilla.New(&fakebugzilla.Fixture{Bugs: []bugzilla.Bug{
+		{ID: 1, Status: "NEW"},
+		{ID: 2, Status: "NEW"},
+	}})
+	defer bz.Close()
+	recorder := events.NewInMemoryRecorder("test")
+	d := &daemon{
+		client:   bz.Client(),
+		eng:      &engine{concurrency: 1, limiter: rate.NewLimiter(rate.Inf, 1)},
+		auditDir: t.TempDir(),
+		recorder: recorder,
+	}
+	action := api.BugAction{
+		Name:    "too many",
+		MaxBugs: 1,
+		Query:   bugzilla.Query{Status: []string{"NEW"}},
+		Update:  bugzilla.BugUpdate{Priority: "low"},
+	}
+	sync := d.actionSync(action)
+	// an error would be retried right away instead of at the next schedule
+	if err := sync(context.Background(), factory.NewSyncContext("test", recorder)); err != nil {
+		t.Errorf("expected no error when maxBugs stops the run, got %v", err)
+	}
+	if bz.Bug(1).Priority == "low" {
+		t.Errorf("expected maxBugs to stop the run")
+	}
+	warned := false
+	for _, event := range recorder.Events() {
+		warned = warned || (event.Reason == "ActionRun" && event.Type == "Warning")
+	}
+	if !warned {
+		t.Errorf("expected a warning event, got %v", recorder.Events())
+	}
+
+	d.stop()
+	action.MaxBugs = 0
+	sync = d.actionSync(action)
+	if err := sync(context.Background(), factory.NewSyncContext("test", recorder)); err != nil {
+		t.Fatal(err)
+	}
+	if bz.Bug(1).Priority == "low" {
+		t.Errorf("expected no run to start once the daemon is stopping")
+	}
+}
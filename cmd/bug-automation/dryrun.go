@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/eparis/bugzilla"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+
+	// flagClear is the status used in a FlagChange to remove a flag from a bug
+	flagClear = "X"
+)
+
+type fieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type bugDiff struct {
+	Action  string        `json:"action"`
+	ID      int           `json:"id"`
+	Summary string        `json:"summary,omitempty"`
+	Skipped string        `json:"skipped,omitempty"`
+	Changes []fieldChange `json:"changes"`
+}
+
+func firstOrEmpty(in []string) string {
+	if len(in) == 0 {
+		return ""
+	}
+	return in[0]
+}
+
+func flagStatus(bug *bugzilla.Bug, name string) string {
+	for _, flag := range bug.Flags {
+		if flag.Name == name {
+			return flag.Status
+		}
+	}
+	return ""
+}
+
+func keywordsAfter(current []string, update *bugzilla.BugKeywords) []string {
+	if len(update.Set) != 0 {
+		return sets.NewString(update.Set...).List()
+	}
+	out := sets.NewString(current...)
+	out.Insert(update.Add...)
+	out.Delete(update.Remove...)
+	return out.List()
+}
+
+// diffBug returns every field which would change if update was applied to bug.
+// Fields which are set in the update but already hold that value are not included.
+func diffBug(bug *bugzilla.Bug, update bugzilla.BugUpdate) []fieldChange {
+	changes := []fieldChange{}
+	add := func(field, old, new string) {
+		if new == "" || old == new {
+			return
+		}
+		changes = append(changes, fieldChange{Field: field, Old: old, New: new})
+	}
+
+	add("status", bug.Status, update.Status)
+	add("resolution", bug.Resolution, update.Resolution)
+	add("target_release", firstOrEmpty(bug.TargetRelease), update.TargetRelease)
+	add("priority", bug.Priority, update.Priority)
+	add("severity", bug.Severity, update.Severity)
+	add("assigned_to", bug.AssignedTo, update.AssignedTo)
+	add("whiteboard", bug.Whiteboard, update.Whiteboard)
+	add("cf_devel_whiteboard", bug.DevelWhiteboard, update.DevWhiteboard)
+
+	for _, flag := range update.Flags {
+		old := flagStatus(bug, flag.Name)
+		new := flag.Status
+		if new == flagClear {
+			if old == "" {
+				continue
+			}
+			changes = append(changes, fieldChange{Field: "flag:" + flag.Name, Old: old, New: ""})
+			continue
+		}
+		add("flag:"+flag.Name, old, new)
+	}
+
+	if update.Keywords != nil {
+		current := sets.NewString(bug.Keywords...).List()
+		after := keywordsAfter(bug.Keywords, update.Keywords)
+		if !sets.NewString(current...).Equal(sets.NewString(after...)) {
+			changes = append(changes, fieldChange{
+				Field: "keywords",
+				Old:   strings.Join(current, ","),
+				New:   strings.Join(after, ","),
+			})
+		}
+	}
+
+	if update.Comment != nil && update.Comment.Body != "" {
+		field := "comment"
+		if update.Comment.Private {
+			field = "comment(private)"
+		}
+		changes = append(changes, fieldChange{Field: field, New: update.Comment.Body})
+	}
+	return changes
+}
+
+// summarize shortens multi-line values, like comments, so they fit in a table
+// cell. It counts runes so a multi-byte character is never cut in half.
+func summarize(in string) string {
+	const max = 60
+	line := strings.SplitN(in, "\n", 2)[0]
+	if runes := []rune(line); len(runes) > max {
+		return string(runes[:max-3]) + "..."
+	}
+	if line != in {
+		return line + "..."
+	}
+	return line
+}
+
+func writeDiffTable(w io.Writer, diffs []bugDiff) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tBUG\tFIELD\tOLD\tNEW")
+	for _, diff := range diffs {
+		if diff.Skipped != "" {
+			fmt.Fprintf(tw, "%s\t%d\t(skipped: %s)\t\t\n", diff.Action, diff.ID, diff.Skipped)
+			continue
+		}
+		if len(diff.Changes) == 0 {
+			fmt.Fprintf(tw, "%s\t%d\t(no changes)\t\t\n", diff.Action, diff.ID)
+			continue
+		}
+		for _, change := range diff.Changes {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", diff.Action, diff.ID, change.Field, summarize(change.Old), summarize(change.New))
+		}
+	}
+	return tw.Flush()
+}
+
+func validateOutput(format string) error {
+	if format != outputTable && format != outputJSON {
+		return fmt.Errorf("unknown output format %q, must be %q or %q", format, outputTable, outputJSON)
+	}
+	return nil
+}
+
+func writeDiffs(w io.Writer, format string, diffs []bugDiff) error {
+	sort.SliceStable(diffs, func(i, j int) bool {
+		if diffs[i].Action != diffs[j].Action {
+			return diffs[i].Action < diffs[j].Action
+		}
+		return diffs[i].ID < diffs[j].ID
+	})
+	if err := validateOutput(format); err != nil {
+		return err
+	}
+	if format == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diffs)
+	}
+	return writeDiffTable(w, diffs)
+}
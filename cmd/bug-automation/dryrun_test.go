@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eparis/bugzilla"
+)
+
+func TestDiffBug(t *testing.T) {
+	bug := &bugzilla.Bug{
+		ID:            1,
+		TargetRelease: []string{"4.5.0"},
+		Keywords:      []string{"UpcomingSprint", "Security"},
+		Flags: []bugzilla.Flag{
+			{Name: "blocker", Status: "?"},
+			{Name: "reviewed-in-sprint", Status: "+"},
+		},
+	}
+	tests := []struct {
+		name     string
+		update   bugzilla.BugUpdate
+		expected []fieldChange
+	}{
+		{
+			name: "target release and private comment",
+			update: bugzilla.BugUpdate{
+				TargetRelease: "---",
+				Comment:       &bugzilla.BugComment{Body: "why", Private: true},
+			},
+			expected: []fieldChange{
+				{Field: "target_release", Old: "4.5.0", New: "---"},
+				{Field: "comment(private)", New: "why"},
+			},
+		},
+		{
+			name: "unchanged fields are skipped",
+			update: bugzilla.BugUpdate{
+				TargetRelease: "4.5.0",
+				Flags:         []bugzilla.FlagChange{{Name: "blocker", Status: "?"}},
+			},
+			expected: []fieldChange{},
+		},
+		{
+			name: "flags set and cleared",
+			update: bugzilla.BugUpdate{
+				Flags: []bugzilla.FlagChange{
+					{Name: "reviewed-in-sprint", Status: "-"},
+					{Name: "blocker", Status: "X"},
+					{Name: "requires_doc_text", Status: "X"},
+				},
+			},
+			expected: []fieldChange{
+				{Field: "flag:reviewed-in-sprint", Old: "+", New: "-"},
+				{Field: "flag:blocker", Old: "?", New: ""},
+			},
+		},
+		{
+			name: "keywords removed",
+			update: bugzilla.BugUpdate{
+				Keywords: &bugzilla.BugKeywords{Remove: []string{"UpcomingSprint"}},
+			},
+			expected: []fieldChange{
+				{Field: "keywords", Old: "Security,UpcomingSprint", New: "Security"},
+			},
+		},
+		{
+			name: "keyword not present is no change",
+			update: bugzilla.BugUpdate{
+				Keywords: &bugzilla.BugKeywords{Remove: []string{"TestBlocker"}},
+			},
+			expected: []fieldChange{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes := diffBug(bug, test.update)
+			if !reflect.DeepEqual(changes, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, changes)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{in: "short", expected: "short"},
+		{in: "first\nsecond", expected: "first..."},
+		{in: strings.Repeat("a", 61), expected: strings.Repeat("a", 57) + "..."},
+		{in: strings.Repeat("ü", 61), expected: strings.Repeat("ü", 57) + "..."},
+	}
+	for _, test := range tests {
+		if got := summarize(test.in); got != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.in, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/bugzilla-tools/pkg/fakebugzilla"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+func newOrgDataServer(t *testing.T) *httptest.Server {
+	orgData := teams.OrgData{
+		Teams: map[string]teams.TeamInfo{
+			"Networking": {Name: "Networking", Components: []string{"Networking"}},
+		},
+		Releases: map[string]teams.ReleaseInfo{
+			"4.6": {Name: "4.6", Targets: []string{"4.6.0", "4.6.z"}, Milestones: &teams.Milestones{GA: "2020-10-27"}},
+			"4.7": {Name: "4.7", Targets: []string{"4.7.0"}, Milestones: &teams.Milestones{GA: "2099-01-01"}},
+		},
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewEncoder(w).Encode(orgData); err != nil {
+			t.Error(err)
+		}
+	}))
+}
+
+func TestEndToEnd(t *testing.T) {
+	bz, err := fakebugzilla.NewFromFile("testdata/bugs.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bz.Close()
+	orgSrv := newOrgDataServer(t)
+	defer orgSrv.Close()
+
+	dir, err := ioutil.TempDir("", "bug-automation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "bugzillaKey")
+	if err := ioutil.WriteFile(keyFile, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	auditDir := filepath.Join(dir, "audit")
+	common := []string{
+		"--bugzilla-url=" + bz.URL,
+		"--bugzilla-key=" + keyFile,
+		"--audit-dir=" + auditDir,
+	}
+
+	cmd := newCommand()
+	cmd.SetArgs(append(common, "--rules=rules", "--actions=bugsTargetOldZero", "--org-data-url="+orgSrv.URL))
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := bz.Bug(1).TargetRelease; !reflect.DeepEqual(got, []string{"---"}) {
+		t.Errorf("expected bug 1 to have its target release unset, got %v", got)
+	}
+	comments := bz.Comments(1)
+	if len(comments) != 1 || !comments[0].IsPrivate || !strings.Contains(comments[0].Text, "already shipped") {
+		t.Errorf("expected a private comment on bug 1, got %#v", comments)
+	}
+	for _, id := range []int{2, 3} {
+		if history := bz.History(id); len(history) != 0 {
+			t.Errorf("expected bug %d to be untouched, got %#v", id, history)
+		}
+	}
+
+	runs, err := ioutil.ReadDir(auditDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("expected one audit log, got %d", len(runs))
+	}
+	run := strings.TrimSuffix(runs[0].Name(), ".jsonl")
+
+	cmd = newCommand()
+	cmd.SetArgs(append([]string{"rollback", "--run=" + run}, common...))
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got := bz.Bug(1).TargetRelease; !reflect.DeepEqual(got, []string{"4.6.0"}) {
+		t.Errorf("expected rollback to restore the target release, got %v", got)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/eparis/bugzilla"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	concurrencyFlagName = "concurrency"
+	rateFlagName        = "rate"
+	maxRetriesFlagName  = "max-retries"
+)
+
+var (
+	// The bugzilla client only reports the HTTP status code inside the error message
+	responseCodeRegexp = regexp.MustCompile(`response code ([0-9]+) not 200`)
+)
+
+// retryable returns true if err was caused by the server being overloaded or
+// rate limiting us. Any other error is assumed to be permanent.
+func retryable(err error) bool {
+	matches := responseCodeRegexp.FindStringSubmatch(err.Error())
+	if len(matches) != 2 {
+		return false
+	}
+	code, convErr := strconv.Atoi(matches[1])
+	if convErr != nil {
+		return false
+	}
+	return code == 429 || code >= 500
+}
+
+// engine runs a function against many bugs in parallel while keeping the total
+// request rate against bugzilla under a limit. The limit is enforced by the
+// client returned by limit, so every request counts, even when a single call
+// makes several.
+type engine struct {
+	concurrency int
+	limiter     *rate.Limiter
+	backoff     wait.Backoff
+}
+
+func newEngine(cmd *cobra.Command) (*engine, error) {
+	concurrency, err := cmd.Flags().GetInt(concurrencyFlagName)
+	if err != nil {
+		return nil, err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	rps, err := cmd.Flags().GetFloat64(rateFlagName)
+	if err != nil {
+		return nil, err
+	}
+	limit := rate.Inf
+	if rps > 0 {
+		limit = rate.Limit(rps)
+	}
+	maxRetries, err := cmd.Flags().GetInt(maxRetriesFlagName)
+	if err != nil {
+		return nil, err
+	}
+	return &engine{
+		concurrency: concurrency,
+		limiter:     rate.NewLimiter(limit, 1),
+		backoff: wait.Backoff{
+			Duration: time.Second,
+			Factor:   2,
+			Jitter:   0.1,
+			Steps:    maxRetries + 1,
+			Cap:      time.Minute,
+		},
+	}, nil
+}
+
+// call runs f, retrying with backoff while f fails with a retryable error.
+func (e *engine) call(f func() error) error {
+	backoff := e.backoff
+	for {
+		err := f()
+		if err == nil || !retryable(err) {
+			return err
+		}
+		if backoff.Steps <= 1 {
+			return err
+		}
+		delay := backoff.Step()
+		logrus.Infof("Retrying in %v after: %v", delay, err)
+		time.Sleep(delay)
+	}
+}
+
+// run calls f for every id using the engine's worker pool. It returns the
+// error for every id which failed.
+func (e *engine) run(ids []int, f func(id int) error) map[int]error {
+	work := make(chan int)
+	failed := map[int]error{}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < e.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range work {
+				id := id
+				if err := e.call(func() error { return f(id) }); err != nil {
+					lock.Lock()
+					failed[id] = err
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	for _, id := range ids {
+		work <- id
+	}
+	close(work)
+	wg.Wait()
+	return failed
+}
+
+// limitedClient waits on the limiter before every request to bugzilla.
+type limitedClient struct {
+	bugzilla.Client
+	limiter *rate.Limiter
+}
+
+// limit returns client with every request held to the engine's rate.
+func (e *engine) limit(client bugzilla.Client) bugzilla.Client {
+	return &limitedClient{Client: client, limiter: e.limiter}
+}
+
+func (c *limitedClient) wait() error {
+	return c.limiter.Wait(context.TODO())
+}
+
+func (c *limitedClient) GetBug(id int) (*bugzilla.Bug, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+	return c.Client.GetBug(id)
+}
+
+func (c *limitedClient) GetBugComments(id int) ([]bugzilla.Comment, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+	return c.Client.GetBugComments(id)
+}
+
+func (c *limitedClient) GetBugHistory(id int) ([]bugzilla.History, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+	return c.Client.GetBugHistory(id)
+}
+
+func (c *limitedClient) Search(query bugzilla.Query) ([]*bugzilla.Bug, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+	return c.Client.Search(query)
+}
+
+func (c *limitedClient) GetExternalBugs(id int) ([]bugzilla.ExternalBug, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+	return c.Client.GetExternalBugs(id)
+}
+
+func (c *limitedClient) GetExternalBugPRsOnBug(id int) ([]bugzilla.ExternalBug, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+	return c.Client.GetExternalBugPRsOnBug(id)
+}
+
+func (c *limitedClient) UpdateBug(id int, update bugzilla.BugUpdate) error {
+	if err := c.wait(); err != nil {
+		return err
+	}
+	return c.Client.UpdateBug(id, update)
+}
+
+func (c *limitedClient) AddPullRequestAsExternalBug(id int, org, repo string, num int) (bool, error) {
+	if err := c.wait(); err != nil {
+		return false, err
+	}
+	return c.Client.AddPullRequestAsExternalBug(id, org, repo, num)
+}
+
+func (c *limitedClient) BugList(queryName, sharerID string) ([]bugzilla.Bug, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+	return c.Client.BugList(queryName, sharerID)
+}
+
+func (c *limitedClient) WithCGIClient(user, password string) bugzilla.Client {
+	return &limitedClient{Client: c.Client.WithCGIClient(user, password), limiter: c.limiter}
+}
+
+func addEngineFlags(cmd *cobra.Command) {
+	cmd.Flags().Int(concurrencyFlagName, 4, "Number of bugs to update in parallel")
+	cmd.Flags().Float64(rateFlagName, 5, "Maximum requests per second sent to bugzilla, 0 means unlimited")
+	cmd.Flags().Int(maxRetriesFlagName, 5, "Number of times to retry a request which failed with a 5xx or rate limit response")
+}
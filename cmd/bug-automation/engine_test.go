@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eparis/bugzilla"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err       error
+		retryable bool
+	}{
+		{err: fmt.Errorf("response code 500 not 200"), retryable: true},
+		{err: fmt.Errorf("response code 503 not 200"), retryable: true},
+		{err: fmt.Errorf("response code 429 not 200"), retryable: true},
+		{err: fmt.Errorf("response code 404 not 200"), retryable: false},
+		{err: fmt.Errorf("connection refused"), retryable: false},
+	}
+	for _, test := range tests {
+		if got := retryable(test.err); got != test.retryable {
+			t.Errorf("%v: expected retryable=%v, got %v", test.err, test.retryable, got)
+		}
+	}
+}
+
+func TestEngineRun(t *testing.T) {
+	e := &engine{
+		concurrency: 3,
+		limiter:     rate.NewLimiter(rate.Inf, 1),
+		backoff:     wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3},
+	}
+	var calls int32
+	failed := e.run([]int{1, 2, 3, 4}, func(id int) error {
+		atomic.AddInt32(&calls, 1)
+		switch id {
+		case 2:
+			return fmt.Errorf("response code 502 not 200")
+		case 3:
+			return fmt.Errorf("response code 400 not 200")
+		}
+		return nil
+	})
+	if len(failed) != 2 || failed[2] == nil || failed[3] == nil {
+		t.Errorf("expected bugs 2 and 3 to fail, got %v", failed)
+	}
+	// 1 and 4 succeed once, 3 fails once, 2 is tried Steps times
+	if calls != 6 {
+		t.Errorf("expected 6 calls, got %d", calls)
+	}
+}
+
+// countingClient counts requests instead of sending them
+type countingClient struct {
+	bugzilla.Client
+	requests int
+}
+
+func (c *countingClient) GetBug(id int) (*bugzilla.Bug, error) {
+	c.requests++
+	return &bugzilla.Bug{ID: id}, nil
+}
+
+func (c *countingClient) UpdateBug(id int, update bugzilla.BugUpdate) error {
+	c.requests++
+	return nil
+}
+
+func TestLimitEveryRequest(t *testing.T) {
+	// three tokens, refilled only once an hour
+	e := &engine{limiter: rate.NewLimiter(rate.Every(time.Hour), 3)}
+	counting := &countingClient{}
+	client := e.limit(counting)
+	if _, err := client.GetBug(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UpdateBug(1, bugzilla.BugUpdate{}); err != nil {
+		t.Fatal(err)
+	}
+	if counting.requests != 2 {
+		t.Errorf("expected 2 requests, got %d", counting.requests)
+	}
+	if !e.limiter.Allow() || e.limiter.Allow() {
+		t.Errorf("expected the GetBug and UpdateBug to use one token each")
+	}
+}
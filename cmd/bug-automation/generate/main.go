@@ -6,360 +6,98 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
-	"github.com/eparis/bugzilla"
 	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
 	"github.com/openshift/bugzilla-tools/pkg/api"
-	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/rules"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
 )
 
 var (
-	testBugs = []string{}
+	testBugs      = []string{}
+	rulesDir      = "../rules"
+	operationsDir = "../operations"
 )
 
-func onEngineeringStatus() []string {
-	return []string{
-		"NEW",
-		"ASSIGNED",
-		"POST",
-		"ON_DEV",
-	}
-}
-
-func allOpenStatus() []string {
-	return append(onEngineeringStatus(), "MODIFIED", "ON_QA")
-}
+type Action api.BugAction
 
-func defaultQuery() bugzilla.Query {
-	query := bugzilla.Query{
-		Classification: []string{"Red Hat"},
-		Product:        []string{"OpenShift Container Platform"},
-		Status:         onEngineeringStatus(),
-		Advanced: []bugzilla.AdvancedQuery{
-			{
-				Field:  "component",
-				Op:     "equals",
-				Value:  "Documentation",
-				Negate: true,
-			},
-			{
-				Field:  "component",
-				Op:     "equals",
-				Value:  "Migration Tooling",
-				Negate: true,
-			},
-			{
-				Field:  "component",
-				Op:     "equals",
-				Value:  "odo",
-				Negate: true,
-			},
-		},
-		IncludeFields: []string{"id"},
-	}
-	if len(testBugs) > 0 {
-		query.BugIDs = testBugs
-		query.BugIDsType = "anyexact"
+func (a *Action) yaml() string {
+	out, err := yaml.Marshal(a)
+	if err != nil {
+		return ""
 	}
-	return query
+	return string(out)
 }
 
-func bugsTargetOldZeroQuery() bugzilla.Query {
-	query := defaultQuery()
-	query.TargetRelease = []string{"4.1.0", "4.2.0", "4.3.0", "4.4.0", "4.5.0", "4.6.0"}
-	return query
+func (a Action) write() error {
+	actionYaml := a.yaml()
+	filename := filepath.Join(operationsDir, fmt.Sprintf("%s.yaml", a.Name))
+	return ioutil.WriteFile(filename, []byte(actionYaml), 0644)
 }
 
-func bugsTargetOldZeroUpdate() bugzilla.BugUpdate {
-	return bugzilla.BugUpdate{
-		TargetRelease: "---",
-		Comment: &bugzilla.BugComment{
-			Private: true,
-			Body:    `Unsetting the target release because this bug targets a .0 release which has already shipped. For example it may target 4.2.0. Since 4.2.0 has already shipped such bugs may instead wish to target a future 4.2.z.`,
-		},
+// getVars returns the values the rule templates may use. The releases come
+// from the org data.
+func getVars(cmd *cobra.Command) (rules.Vars, error) {
+	orgData, err := teams.GetOrgData(cmd)
+	if err != nil {
+		return rules.Vars{}, err
 	}
-}
-
-func bugsWithUpcomingSprintQuery() bugzilla.Query {
-	query := defaultQuery()
-	query.Status = allOpenStatus()
-	query.Keywords = []string{"UpcomingSprint"}
-	query.KeywordsType = "allwords"
-	return query
-}
-
-func bugsWithReviewedInSprintFlagQuery() bugzilla.Query {
-	query := defaultQuery()
-	query.Advanced = append(query.Advanced, []bugzilla.AdvancedQuery{
-		{
-			Field: "flagtypes.name",
-			Op:    "equals",
-			Value: bugs.ReviewedInSprintFlagName + bugs.FlagTrue,
-		},
-		{
-			Field: "target_release",
-			Op:    "notregexp",
-			Value: `premerge$`,
-		},
-	}...)
-	return query
-}
-
-func bugsWithReviewedInSprintFlagUpdate() bugzilla.BugUpdate {
-	return bugzilla.BugUpdate{
-		Flags: []bugzilla.FlagChange{
-			{
-				Name:   bugs.ReviewedInSprintFlagName,
-				Status: bugs.FlagFalse,
-			},
-		},
-		MinorUpdate: true,
+	vars, err := rules.NewVars(orgData, time.Now())
+	if err != nil {
+		return vars, err
 	}
+	vars.TestBugs = testBugs
+	return vars, nil
 }
 
-func bugsWithUpcomingSprintUpdate() bugzilla.BugUpdate {
-	return bugzilla.BugUpdate{
-		Keywords: &bugzilla.BugKeywords{
-			Remove: []string{"UpcomingSprint"},
-		},
-		MinorUpdate: true,
+func loadRules(cmd *cobra.Command) (rules.RuleSet, rules.Vars, error) {
+	vars, err := getVars(cmd)
+	if err != nil {
+		return nil, vars, err
 	}
+	rs, err := rules.Load(rulesDir, vars)
+	return rs, vars, err
 }
 
-func bugsWithoutZQuery() bugzilla.Query {
-	query := defaultQuery()
-	query.Keywords = []string{"Security"}
-	query.KeywordsType = "nowords"
-	query.Advanced = append(query.Advanced, []bugzilla.AdvancedQuery{
-		{
-			Field: "dependson",
-			Op:    "isempty",
-		},
-		{
-			Field: "target_release",
-			Op:    "regexp",
-			Value: `^4\.[0-9]+\.z$`,
-		},
-		{
-			Field:  "component",
-			Op:     "equals",
-			Value:  "Release",
-			Negate: true,
-		},
-		{
-			Field:  "component",
-			Op:     "equals",
-			Value:  "Logging",
-			Negate: true,
-		},
-		// if a bug is marked as a blocker+, don't remove the target release
-		// because we risk unblocking the release
-		{
-			Field:  "flagtypes.name",
-			Op:     "equals",
-			Value:  bugs.BlockerFlagName + bugs.FlagTrue,
-			Negate: true,
-		},
-	}...)
-	return query
-}
-
-func bugsWithoutZUpdate() bugzilla.BugUpdate {
-	return bugzilla.BugUpdate{
-		TargetRelease: "---",
-		Comment: &bugzilla.BugComment{
-			Private: true,
-			Body: `This bug sets Target Release equal to a z-stream but has no bug in the 'Depends On' field. As such this is not a valid bug state and the target release is being unset.
-
-Any bug targeting 4.1.z must have a bug targeting 4.2 in 'Depends On.'
-Similarly, any bug targeting 4.2.z must have a bug with Target Release of 4.3 in 'Depends On.'`,
-		},
+func doGenerate(cmd *cobra.Command) error {
+	rs, vars, err := loadRules(cmd)
+	if err != nil {
+		return err
 	}
-}
-
-func targetReleaseWithoutSeverityQuery() bugzilla.Query {
-	query := defaultQuery()
-	query.Severity = []string{"unspecified"}
-	query.Advanced = append(query.Advanced, bugzilla.AdvancedQuery{
-		Field:  "target_release",
-		Op:     "equals",
-		Value:  "---",
-		Negate: true,
-	})
-	return query
-}
-
-func targetReleaseWithoutSeverityUpdate() bugzilla.BugUpdate {
-	return bugzilla.BugUpdate{
-		TargetRelease: "---",
-		Comment: &bugzilla.BugComment{
-			Private: true,
-			Body:    `This bug has set a target release without specifying a severity. As part of triage when determining the importance of bugs a severity should be specified. Since these bugs have not been properly triaged we are removing the target release. Teams will need to add a severity before setting the target release again.`,
-		},
+	if err := utilerrors.NewAggregate(rs.Validate()); err != nil {
+		return err
 	}
-}
-
-func needsBlockerFlagQuery() bugzilla.Query {
-	query := defaultQuery()
-	query.Advanced = append(query.Advanced, []bugzilla.AdvancedQuery{
-		{
-			Field:  "flagtypes.name",
-			Op:     "substring",
-			Value:  bugs.BlockerFlagName,
-			Negate: true,
-		},
-		{
-			Field: "target_release",
-			Op:    "notregexp",
-			Value: `^4\.[0-9]+\.z$|premerge$`,
-		},
-		{
-			Field: "version",
-			Op:    "notregexp",
-			Value: `^2\.`,
-		},
-		{
-			Field: "version",
-			Op:    "notregexp",
-			Value: `^3\.`,
-		},
-	}...)
-	return query
-}
-
-func bugsNeedBlockerFlagSeverityQuery() bugzilla.Query {
-	query := needsBlockerFlagQuery()
-	query.Severity = []string{"high", "urgent"}
-	return query
-}
-
-func bugsNeedBlockerFlagPriorityQuery() bugzilla.Query {
-	query := needsBlockerFlagQuery()
-	query.Priority = []string{"high", "urgent"}
-	return query
-}
-
-func bugsNeedBlockerFlagAction() bugzilla.BugUpdate {
-	return bugzilla.BugUpdate{
-		Flags: []bugzilla.FlagChange{
-			{
-				Name:   bugs.BlockerFlagName,
-				Status: bugs.FlagRequested,
-			},
-		},
-		MinorUpdate: true,
+	actions, err := rs.Actions(vars)
+	if err != nil {
+		return err
 	}
-}
-
-func blockerPlusWithoutTargetReleaseQuery() bugzilla.Query {
-	query := defaultQuery()
-	query.Advanced = append(query.Advanced, bugzilla.AdvancedQuery{
-		Field: "flagtypes.name",
-		Op:    "substring",
-		Value: bugs.BlockerFlagName + bugs.FlagTrue,
-	})
-	query.TargetRelease = []string{"---"}
-	return query
-}
 
-func blockerPlusWithoutTargetReleaseAction() bugzilla.BugUpdate {
-	update := bugsNeedBlockerFlagAction()
-	update.MinorUpdate = false
-	update.Comment = &bugzilla.BugComment{
-		Private: true,
-		Body:    `This bug sets blocker+ without setting a Target Release. This is an invalid state as it is impossible to determine what is being blocked. Please be sure to set Priority, Severity, and Target Release before you attempt to set blocker+`,
+	for _, action := range actions {
+		if err := Action(action).write(); err != nil {
+			return err
+		}
 	}
-	return update
-}
 
-func noUpdate() bugzilla.BugUpdate {
-	return bugzilla.BugUpdate{}
+	return nil
 }
 
-type Action api.BugAction
-
-func (a *Action) yaml() string {
-	out, err := yaml.Marshal(a)
+func doValidate(cmd *cobra.Command) error {
+	rs, _, err := loadRules(cmd)
 	if err != nil {
-		return ""
+		return err
 	}
-	return string(out)
-}
-
-func (a Action) write() error {
-	actionYaml := a.yaml()
-	filename := fmt.Sprintf("../operations/%s.yaml", a.Name)
-	return ioutil.WriteFile(filename, []byte(actionYaml), 0644)
-}
-
-func doGenerate() error {
-	actions := []Action{
-		{
-			Name:        "targetReleaseWithoutSeverity",
-			Description: "Bugs Setting Target Release Without Severity Set",
-			Query:       targetReleaseWithoutSeverityQuery(),
-			Update:      targetReleaseWithoutSeverityUpdate(),
-			Default:     true,
-		},
-		{
-			Name:        "zNoDepends",
-			Description: "Z-Stream Bugs With No Depends On",
-			Query:       bugsWithoutZQuery(),
-			Update:      bugsWithoutZUpdate(),
-			Default:     true,
-		},
-		{
-			Name:        "removeUpcomingSprint",
-			Description: "Remove UpcomingSprint from all bugs",
-			Query:       bugsWithUpcomingSprintQuery(),
-			Update:      bugsWithUpcomingSprintUpdate(),
-			Default:     false,
-		},
-		{
-			Name:        "removeReviewedInSprint",
-			Description: "Set reviewed-in-sprint flag to '-' in all bugs",
-			Query:       bugsWithReviewedInSprintFlagQuery(),
-			Update:      bugsWithReviewedInSprintFlagUpdate(),
-			Default:     false,
-		},
-		{
-			Name:        "bugsTargetOldZero",
-			Description: "Open bugs which target closed releases",
-			Query:       bugsTargetOldZeroQuery(),
-			Update:      bugsTargetOldZeroUpdate(),
-			Default:     true,
-		},
-		{
-			Name:        "bugsNeedBlockerFlagSeverity",
-			Description: "All bugs that should have at least blocker? based on the severity",
-			Query:       bugsNeedBlockerFlagSeverityQuery(),
-			Update:      bugsNeedBlockerFlagAction(),
-			Default:     true,
-		},
-		{
-			Name:        "bugsNeedBlockerFlagPriority",
-			Description: "All bugs that should have at least blocker? based on the priority",
-			Query:       bugsNeedBlockerFlagPriorityQuery(),
-			Update:      bugsNeedBlockerFlagAction(),
-			Default:     true,
-		},
-		{
-			Name:        "blockerPlusWithoutTargetRelease",
-			Description: "All bugs that set blocker+ must also set a TargetRelease",
-			Query:       blockerPlusWithoutTargetReleaseQuery(),
-			Update:      blockerPlusWithoutTargetReleaseAction(),
-			Default:     true,
-		},
+	errs := rs.Validate()
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
 	}
-
-	for _, action := range actions {
-		if err := action.write(); err != nil {
-			return err
-		}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d problems found in %s", len(errs), rulesDir)
 	}
-
+	fmt.Printf("%d rules in %s are valid\n", len(rs), rulesDir)
 	return nil
 }
 
@@ -367,15 +105,28 @@ func main() {
 	cmd := &cobra.Command{
 		Use: filepath.Base(os.Args[0]),
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			err := doGenerate()
+			err := doGenerate(cmd)
 			if err != nil {
 				return err
 			}
 			return nil
 		},
 	}
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check that every rule renders into a valid bugzilla query and update",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return doValidate(cmd)
+		},
+	}
+	cmd.AddCommand(validateCmd)
+
 	cmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
-	cmd.Flags().StringSliceVar(&testBugs, "test-bugs", testBugs, "Limit queries to only these specific bugs (CSV)")
+	cmd.PersistentFlags().StringSliceVar(&testBugs, "test-bugs", testBugs, "Limit queries to only these specific bugs (CSV)")
+	cmd.PersistentFlags().StringVar(&rulesDir, "rules", rulesDir, "Directory containing the templated rule yaml")
+	cmd.Flags().StringVar(&operationsDir, "operations", operationsDir, "Directory to write the rendered operations to")
+	teams.AddFlags(cmd)
+	teams.AddFlags(validateCmd)
 	if err := cmd.Execute(); err != nil {
 		os.Exit(1)
 	}
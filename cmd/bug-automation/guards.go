@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eparis/bugzilla"
+
+	"github.com/openshift/bugzilla-tools/pkg/api"
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+)
+
+// checkMaxBugs fails if the query found more bugs than the action allows. A
+// query which matches far more bugs than expected is most likely broken.
+func checkMaxBugs(action api.BugAction, found int) error {
+	if action.MaxBugs > 0 && found > action.MaxBugs {
+		return fmt.Errorf("%s: query matched %d bugs which is more than maxBugs=%d, refusing to run", action.Name, found, action.MaxBugs)
+	}
+	return nil
+}
+
+// skipReason returns why a bug must not be updated, or "" if all of the
+// preconditions pass. If the bug's change time can not be understood the bug
+// is skipped, since we can not prove it is safe to update.
+func skipReason(pre *api.BugPreconditions, bug *bugzilla.Bug, now time.Time) string {
+	if pre == nil {
+		return ""
+	}
+	b := bugs.Bug(*bug)
+	for _, flag := range pre.SkipFlags {
+		name, status := flag, ""
+		if strings.HasSuffix(flag, bugs.FlagTrue) || strings.HasSuffix(flag, bugs.FlagRequested) || strings.HasSuffix(flag, bugs.FlagFalse) {
+			name, status = flag[:len(flag)-1], flag[len(flag)-1:]
+		}
+		if b.Flag(name, status) {
+			return fmt.Sprintf("has flag %s", flag)
+		}
+	}
+	for _, skip := range pre.SkipKeywords {
+		for _, keyword := range bug.Keywords {
+			if keyword == skip {
+				return fmt.Sprintf("has keyword %s", keyword)
+			}
+		}
+	}
+	if pre.SkipChangedWithin != nil {
+		changed, err := bugs.ParseTime(bug.LastChangeTime)
+		if err != nil {
+			return fmt.Sprintf("unable to parse last change time %q", bug.LastChangeTime)
+		}
+		if now.Sub(changed) < pre.SkipChangedWithin.Duration {
+			return fmt.Sprintf("changed within the last %v", pre.SkipChangedWithin.Duration)
+		}
+	}
+	return ""
+}
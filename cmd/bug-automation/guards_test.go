@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eparis/bugzilla"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/bugzilla-tools/pkg/api"
+)
+
+func TestSkipReason(t *testing.T) {
+	now := time.Date(2020, 5, 20, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		pre  *api.BugPreconditions
+		bug  bugzilla.Bug
+		skip bool
+	}{
+		{
+			name: "no preconditions",
+			bug:  bugzilla.Bug{Flags: []bugzilla.Flag{{Name: "blocker", Status: "+"}}},
+		},
+		{
+			name: "blocker+ skipped",
+			pre:  &api.BugPreconditions{SkipFlags: []string{"blocker+"}},
+			bug:  bugzilla.Bug{Flags: []bugzilla.Flag{{Name: "blocker", Status: "+"}}},
+			skip: true,
+		},
+		{
+			name: "blocker? not skipped by blocker+",
+			pre:  &api.BugPreconditions{SkipFlags: []string{"blocker+"}},
+			bug:  bugzilla.Bug{Flags: []bugzilla.Flag{{Name: "blocker", Status: "?"}}},
+		},
+		{
+			name: "any blocker status skipped",
+			pre:  &api.BugPreconditions{SkipFlags: []string{"blocker"}},
+			bug:  bugzilla.Bug{Flags: []bugzilla.Flag{{Name: "blocker", Status: "-"}}},
+			skip: true,
+		},
+		{
+			name: "keyword skipped",
+			pre:  &api.BugPreconditions{SkipKeywords: []string{"Security"}},
+			bug:  bugzilla.Bug{Keywords: []string{"Security"}},
+			skip: true,
+		},
+		{
+			name: "recently changed skipped",
+			pre:  &api.BugPreconditions{SkipChangedWithin: &metav1.Duration{Duration: 24 * time.Hour}},
+			bug:  bugzilla.Bug{LastChangeTime: "2020-05-20T10:45:16Z"},
+			skip: true,
+		},
+		{
+			name: "old change not skipped",
+			pre:  &api.BugPreconditions{SkipChangedWithin: &metav1.Duration{Duration: 24 * time.Hour}},
+			bug:  bugzilla.Bug{LastChangeTime: "2020-05-10T10:45:16Z"},
+		},
+		{
+			name: "unknown change time fails closed",
+			pre:  &api.BugPreconditions{SkipChangedWithin: &metav1.Duration{Duration: 24 * time.Hour}},
+			bug:  bugzilla.Bug{},
+			skip: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason := skipReason(test.pre, &test.bug, now)
+			if (reason != "") != test.skip {
+				t.Errorf("expected skip=%v, got reason %q", test.skip, reason)
+			}
+		})
+	}
+}
+
+func TestCheckMaxBugs(t *testing.T) {
+	action := api.BugAction{Name: "test", MaxBugs: 10}
+	if err := checkMaxBugs(action, 10); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := checkMaxBugs(action, 11); err == nil {
+		t.Errorf("expected an error when the query matches more than maxBugs")
+	}
+	if err := checkMaxBugs(api.BugAction{}, 100000); err != nil {
+		t.Errorf("unexpected error with no limit: %v", err)
+	}
+}
@@ -2,25 +2,61 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"sync"
+	"time"
 
+	"github.com/eparis/bugzilla"
 	"github.com/ghodss/yaml"
 	//"github.com/kr/pretty"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
 	"github.com/openshift/bugzilla-tools/pkg/api"
 	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/rules"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
 )
 
-func getBugActions() ([]api.BugAction, error) {
+// getRuleActions renders the templated rules in dir, so new rules can be run
+// without regenerating operations/.
+func getRuleActions(cmd *cobra.Command, dir string) ([]api.BugAction, error) {
+	orgData, err := teams.GetOrgData(cmd)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := rules.NewVars(orgData, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	rs, err := rules.Load(dir, vars)
+	if err != nil {
+		return nil, err
+	}
+	if err := utilerrors.NewAggregate(rs.Validate()); err != nil {
+		return nil, err
+	}
+	return rs.Actions(vars)
+}
+
+func getBugActions(cmd *cobra.Command) ([]api.BugAction, error) {
+	rulesDir, err := cmd.Flags().GetString("rules")
+	if err != nil {
+		return nil, err
+	}
+	if rulesDir != "" {
+		return getRuleActions(cmd, rulesDir)
+	}
+
 	var pathNames []string
 
 	root := "operations"
-	err := filepath.Walk(root, func(pathName string, info os.FileInfo, err error) error {
+	err = filepath.Walk(root, func(pathName string, info os.FileInfo, err error) error {
 		if path.Ext(pathName) != ".yaml" {
 			return nil
 		}
@@ -70,7 +106,19 @@ func doBug(cmd *cobra.Command) error {
 	}
 	selectedActions := actionNames(actionSlice)
 
-	potentialActions, err := getBugActions()
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	if err := validateOutput(output); err != nil {
+		return err
+	}
+
+	potentialActions, err := getBugActions(cmd)
 	if err != nil {
 		return err
 	}
@@ -87,28 +135,177 @@ func doBug(cmd *cobra.Command) error {
 			continue
 		}
 	}
+	eng, err := newEngine(cmd)
+	if err != nil {
+		return err
+	}
+	checkpointPath, err := cmd.Flags().GetString(checkpointFlagName)
+	if err != nil {
+		return err
+	}
+	cp, err := loadCheckpoint(checkpointPath)
+	if err != nil {
+		return err
+	}
+	auditDir, err := cmd.Flags().GetString(auditDirFlagName)
+	if err != nil {
+		return err
+	}
+	var audit *auditLog
+	if !dryRun {
+		audit, err = newAuditLog(auditDir, "run")
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
+		logrus.Infof("Recording changes for run %s in %s", audit.run, auditDir)
+	}
+
+	r := &runner{
+		client: eng.limit(client),
+		eng:    eng,
+		cp:     cp,
+		audit:  audit,
+		dryRun: dryRun,
+	}
 	logrus.Infof("Running: %v", actions)
+	diffs := []bugDiff{}
+	errs := []error{}
 	for _, action := range actions {
-		query := action.Query
-		bugs, err := client.Search(query)
+		result, err := r.runAction(action)
 		if err != nil {
 			return err
 		}
-		logrus.Infof("%q will update %d bugs", action.Description, len(bugs))
+		diffs = append(diffs, result.diffs...)
+		errs = append(errs, result.errs...)
+	}
+	if dryRun {
+		if err := writeDiffs(os.Stdout, output, diffs); err != nil {
+			return err
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// runner applies actions to bugzilla. It is used by both a single run and
+// the daemon.
+type runner struct {
+	client bugzilla.Client
+	eng    *engine
+	cp     *checkpoint
+	audit  *auditLog
+	dryRun bool
+}
+
+// actionResult counts what happened to the bugs matched by an action.
+type actionResult struct {
+	matched int
+	updated int
+	skipped int
+	failed  int
+	diffs   []bugDiff
+	errs    []error
+}
+
+// runAction updates, or in a dry run diffs, every bug matched by action.
+// Failures for individual bugs are collected in the result, an error is only
+// returned if the query itself failed.
+func (r *runner) runAction(action api.BugAction) (*actionResult, error) {
+	result := &actionResult{}
+	var found []*bugzilla.Bug
+	err := r.eng.call(func() error {
+		var err error
+		found, err = r.client.Search(action.Query)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.matched = len(found)
+	if err := checkMaxBugs(action, len(found)); err != nil {
+		logrus.Error(err)
+		result.errs = append(result.errs, err)
+		return result, nil
+	}
+
+	ids := []int{}
+	for _, bug := range found {
+		if !r.dryRun && r.cp.Has(action.Name, bug.ID) {
+			continue
+		}
+		ids = append(ids, bug.ID)
+	}
+	if skipped := len(found) - len(ids); skipped > 0 {
+		logrus.Infof("%q skipping %d bugs already updated according to checkpoint", action.Description, skipped)
+		result.skipped += skipped
+	}
+	logrus.Infof("%q will update %d bugs", action.Description, len(ids))
 
-		update := action.Update
-		for _, bug := range bugs {
-			logrus.Infof("Updating %d", bug.ID)
-			err := client.UpdateBug(bug.ID, update)
+	update := action.Update
+	var lock sync.Mutex
+	if r.dryRun {
+		failed := r.eng.run(ids, func(id int) error {
+			full, err := r.client.GetBug(id)
 			if err != nil {
-				logrus.Infof("Unable to update %d: %v", bug.ID, err)
+				return err
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			diff := bugDiff{
+				Action:  action.Name,
+				ID:      full.ID,
+				Summary: full.Summary,
+				Skipped: skipReason(action.Preconditions, full, time.Now()),
+			}
+			if diff.Skipped == "" {
+				diff.Changes = diffBug(full, update)
 			}
+			result.diffs = append(result.diffs, diff)
+			return nil
+		})
+		for id, err := range failed {
+			result.errs = append(result.errs, fmt.Errorf("%s: unable to get %d: %v", action.Name, id, err))
+		}
+		result.failed = len(failed)
+		return result, nil
+	}
+
+	failed := r.eng.run(ids, func(id int) error {
+		skipped, err := applyUpdate(r.client, r.audit, action.Name, id, update, action.Preconditions)
+		if err != nil {
+			return err
+		}
+		if skipped != "" {
+			logrus.Infof("Skipping %d: %s", id, skipped)
+			lock.Lock()
+			result.skipped++
+			lock.Unlock()
+			return nil
 		}
+		if err := r.cp.Record(action.Name, id); err != nil {
+			return err
+		}
+		lock.Lock()
+		result.updated++
+		lock.Unlock()
+		return nil
+	})
+	for id, err := range failed {
+		logrus.Errorf("Unable to update %d: %v", id, err)
+		result.errs = append(result.errs, fmt.Errorf("%s: unable to update %d: %v", action.Name, id, err))
 	}
-	return nil
+	result.failed = len(failed)
+	if len(failed) == 0 {
+		if err := r.cp.Clear(action.Name); err != nil {
+			return nil, err
+		}
+	} else {
+		logrus.Errorf("%q failed to update %d of %d bugs, rerun to resume", action.Description, len(failed), len(ids))
+	}
+	return result, nil
 }
 
-func main() {
+func newCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use: filepath.Base(os.Args[0]),
 		RunE: func(cmd *cobra.Command, _ []string) error {
@@ -121,7 +318,21 @@ func main() {
 	}
 	cmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
 	cmd.Flags().StringSlice("actions", []string{}, "Actions to run, unset runs all actions with default=true")
+	cmd.Flags().Bool("dry-run", false, "Do not update any bugs, instead print the changes each action would make")
+	cmd.Flags().String("output", outputTable, "Format of the dry-run report, either table or json")
+	cmd.Flags().String(checkpointFlagName, "", "Path to a file recording updated bugs so an interrupted run can be resumed")
+	cmd.Flags().String("rules", "", "Load actions from the templated rules in this directory instead of operations/")
+	addAuditFlags(cmd)
+	addEngineFlags(cmd)
 	bugs.AddFlags(cmd)
+	teams.AddFlags(cmd)
+	cmd.AddCommand(newRollbackCommand())
+	cmd.AddCommand(newDaemonCommand())
+	return cmd
+}
+
+func main() {
+	cmd := newCommand()
 	if err := cmd.Execute(); err != nil {
 		os.Exit(1)
 	}
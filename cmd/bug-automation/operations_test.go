@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/rules"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+// TestOperationsAreGenerated checks operations/ is what generate/ renders
+// from rules/ while 4.7 is the current release.
+func TestOperationsAreGenerated(t *testing.T) {
+	orgData := &teams.OrgData{Releases: map[string]teams.ReleaseInfo{
+		"4.5": {Name: "4.5", Targets: []string{"4.5.z"}},
+		"4.6": {Name: "4.6", Targets: []string{"4.6.z"}},
+		"4.7": {Name: "4.7", Targets: []string{"4.7.0", "4.7.z"}},
+	}}
+	vars, err := rules.NewVars(orgData, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := rules.Load("rules", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := rs.Actions(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("rules", "", "")
+	generated, err := getBugActions(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]string{}
+	for _, action := range generated {
+		data, _ := json.Marshal(action)
+		byName[action.Name] = string(data)
+	}
+	if len(rendered) != len(generated) {
+		t.Errorf("expected %d actions in operations/, got %d, regenerate it", len(rendered), len(generated))
+	}
+	for _, action := range rendered {
+		data, _ := json.Marshal(action)
+		if byName[action.Name] != string(data) {
+			t.Errorf("operations/%s.yaml does not match rules/, regenerate it:\nexpected %s\ngot      %s", action.Name, data, byName[action.Name])
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+)
+
+// The API serves
+//
+//	/api                 every bug by team, as it always has
+//	/api/teams/{team}    the bugs of a team
+//	/api/people/{email}  the bugs assigned to someone
+//	/api/bugs/{id}       a single bug
+//
+// Every endpoint takes fields, the comma separated bug fields to return, all
+// of them if unset. Every endpoint but /api/bugs/{id} takes the filters
+//
+//	severity, priority, status, target_release, keyword, flag
+//	    comma separated values, a bug matches if it has any of them. A flag is
+//	    its name optionally followed by a status, eg blocker or blocker%2B
+//	filter
+//	    a bugs.Filter expression
+//
+// and the lists of a team or person also take limit and offset. Those lists
+// are sorted by bug id and their length before paging is in X-Total-Count.
+// Every response carries an ETag and If-None-Match is honoured.
+
+// bugMatcher returns a function which is true for the bugs matching the
+// filter query parameters.
+func bugMatcher(r *http.Request, bugData *bugs.BugData) (func(*bugs.Bug) bool, error) {
+	query := r.URL.Query()
+	list := func(name string) sets.String {
+		out := sets.NewString()
+		for _, value := range query[name] {
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					out.Insert(v)
+				}
+			}
+		}
+		return out
+	}
+	severities := list("severity")
+	priorities := list("priority")
+	statuses := list("status")
+	targets := list("target_release")
+	keywords := list("keyword")
+	flags := list("flag")
+
+	var filter *bugs.Filter
+	if source := query.Get("filter"); source != "" {
+		var err error
+		filter, err = bugs.ParseFilter(source)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hasFlag := func(bug *bugs.Bug) bool {
+		for _, flag := range flags.UnsortedList() {
+			name, status := flag, ""
+			if last := flag[len(flag)-1:]; last == bugs.FlagTrue || last == bugs.FlagFalse || last == bugs.FlagRequested {
+				name, status = flag[:len(flag)-1], last
+			}
+			if bug.Flag(name, status) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(bug *bugs.Bug) bool {
+		if severities.Len() > 0 && !severities.Has(bug.Severity) {
+			return false
+		}
+		if priorities.Len() > 0 && !priorities.Has(bug.Priority) {
+			return false
+		}
+		if statuses.Len() > 0 && !statuses.Has(bug.Status) {
+			return false
+		}
+		if targets.Len() > 0 && !targets.HasAny(bug.TargetRelease...) {
+			return false
+		}
+		if keywords.Len() > 0 && !keywords.HasAny(bug.Keywords...) {
+			return false
+		}
+		if flags.Len() > 0 && !hasFlag(bug) {
+			return false
+		}
+		if filter != nil && !filter.Match(bug, bugData.OrgData()) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+func filterBugs(in []*bugs.Bug, match func(*bugs.Bug) bool) []*bugs.Bug {
+	out := []*bugs.Bug{}
+	for _, bug := range in {
+		if match(bug) {
+			out = append(out, bug)
+		}
+	}
+	return out
+}
+
+// project returns the bug with only the fields asked for in the fields query
+// parameter
+func project(r *http.Request, bug *bugs.Bug) (interface{}, error) {
+	fields := r.URL.Query().Get("fields")
+	if fields == "" {
+		return bug, nil
+	}
+	data, err := json.Marshal(bug)
+	if err != nil {
+		return nil, err
+	}
+	all := map[string]interface{}{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{}
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if value, ok := all[field]; ok {
+			out[field] = value
+		}
+	}
+	return out, nil
+}
+
+func projectAll(r *http.Request, in []*bugs.Bug) ([]interface{}, error) {
+	out := make([]interface{}, 0, len(in))
+	for _, bug := range in {
+		p, err := project(r, bug)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// page sorts the bugs by id and returns the page asked for by the limit and
+// offset query parameters
+func page(r *http.Request, in []*bugs.Bug) ([]*bugs.Bug, error) {
+	sorted := make([]*bugs.Bug, len(in))
+	copy(sorted, in)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	query := r.URL.Query()
+	offset, limit := 0, len(sorted)
+	if value := query.Get("offset"); value != "" {
+		o, err := strconv.Atoi(value)
+		if err != nil || o < 0 {
+			return nil, fmt.Errorf("offset must be a number >= 0, got %q", value)
+		}
+		offset = o
+	}
+	if value := query.Get("limit"); value != "" {
+		l, err := strconv.Atoi(value)
+		if err != nil || l < 0 {
+			return nil, fmt.Errorf("limit must be a number >= 0, got %q", value)
+		}
+		limit = l
+	}
+	if offset > len(sorted) {
+		offset = len(sorted)
+	}
+	end := offset + limit
+	if end > len(sorted) {
+		end = len(sorted)
+	}
+	return sorted[offset:end], nil
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSON encodes out with an ETag of its content, and only an empty 304 if
+// the client already has it.
+func writeJSON(w http.ResponseWriter, r *http.Request, bugData *bugs.BugData, out interface{}) {
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(out); err != nil {
+		http.Error(w, fmt.Sprintf("Unable to encode: %v", err), http.StatusInternalServerError)
+		return
+	}
+	etag := fmt.Sprintf("%q", fmt.Sprintf("%x", sha256.Sum256(buf.Bytes())))
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("ETag", etag)
+	bugs.SetAgeHeaders(w.Header(), bugData.Updated())
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		fmt.Printf("Unable to write response: %v\n", err)
+	}
+}
+
+// writeList filters, pages and projects a list of bugs
+func writeList(w http.ResponseWriter, r *http.Request, bugData *bugs.BugData, in []*bugs.Bug) {
+	match, err := bugMatcher(r, bugData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filtered := filterBugs(in, match)
+	paged, err := page(r, filtered)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	out, err := projectAll(r, paged)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(filtered)))
+	writeJSON(w, r, bugData, out)
+}
+
+// GetAPIHandler serves every bug by team
+func GetAPIHandler(bugData *bugs.BugData) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		match, err := bugMatcher(r, bugData)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		out := map[string][]interface{}{}
+		for team, teamBugs := range bugData.GetTeamMap() {
+			out[team], err = projectAll(r, filterBugs(teamBugs, match))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		writeJSON(w, r, bugData, out)
+	}
+}
+
+// GetAPIResourceHandler serves the /api/{teams,people,bugs}/... endpoints
+func GetAPIResourceHandler(bugData *bugs.BugData) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/api/"), "/", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			http.NotFound(w, r)
+			return
+		}
+		kind, name := parts[0], parts[1]
+
+		switch kind {
+		case "teams":
+			teamBugs, ok := bugData.GetTeamMap()[name]
+			if !ok {
+				http.Error(w, fmt.Sprintf("team %q not found", name), http.StatusNotFound)
+				return
+			}
+			writeList(w, r, bugData, teamBugs)
+		case "people":
+			writeList(w, r, bugData, bugData.GetPeopleMap()[name])
+		case "bugs":
+			id, err := strconv.Atoi(name)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("bug id must be a number, got %q", name), http.StatusBadRequest)
+				return
+			}
+			for _, bug := range bugData.GetBugs() {
+				if bug.ID != id {
+					continue
+				}
+				out, err := project(r, bug)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				writeJSON(w, r, bugData, out)
+				return
+			}
+			http.Error(w, fmt.Sprintf("bug %d not found", id), http.StatusNotFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
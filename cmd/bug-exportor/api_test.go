@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/fakebugzilla"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+// TestMain serves --test-bug-data from a fake bugzilla
+func TestMain(m *testing.M) {
+	fakes := &fakebugzilla.TestClients{}
+	bugs.TestClient = fakes.Client
+	code := m.Run()
+	fakes.Close()
+	os.Exit(code)
+}
+
+func TestAPI(t *testing.T) {
+	cmd := &cobra.Command{}
+	bugs.AddFlags(cmd)
+	if err := cmd.Flags().Parse([]string{"--test-bug-data=testdata/bugs.yaml"}); err != nil {
+		t.Fatal(err)
+	}
+	orgData := &teams.OrgData{
+		Teams: map[string]teams.TeamInfo{
+			"Networking": {Name: "Networking", Components: []string{"Networking"}},
+		},
+	}
+	bugData, err := bugs.GetBugData(cmd, orgData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/api", GetAPIHandler(bugData))
+	mux.Handle("/api/", GetAPIResourceHandler(bugData))
+
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	ids := func(w *httptest.ResponseRecorder) []int {
+		out := []struct {
+			ID int `json:"id"`
+		}{}
+		if err := json.NewDecoder(w.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		found := []int{}
+		for _, bug := range out {
+			found = append(found, bug.ID)
+		}
+		return found
+	}
+
+	tests := []struct {
+		path     string
+		expected []int
+		total    string
+	}{
+		{path: "/api/teams/Networking", expected: []int{1, 2, 3}, total: "3"},
+		{path: "/api/teams/Networking?severity=urgent,high&status=NEW", expected: []int{1, 3}, total: "2"},
+		{path: "/api/teams/Networking?flag=blocker%2B", expected: []int{1}, total: "1"},
+		{path: "/api/teams/Networking?keyword=Regression", expected: []int{3}, total: "1"},
+		{path: "/api/teams/Networking?filter=priority+!%3D+low&limit=1&offset=1", expected: []int{3}, total: "2"},
+		{path: "/api/people/someone@example.com?priority=low", expected: []int{2}, total: "1"},
+		{path: "/api/people/nobody@example.com", expected: []int{}, total: "0"},
+	}
+	for _, test := range tests {
+		w := get(test.path, "")
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d: %s", test.path, w.Code, w.Body)
+			continue
+		}
+		if total := w.Header().Get("X-Total-Count"); total != test.total {
+			t.Errorf("%s: expected a total of %s, got %s", test.path, test.total, total)
+		}
+		if got := ids(w); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.path, test.expected, got)
+		}
+	}
+
+	w := get("/api/bugs/3?fields=id,summary", "")
+	bug := map[string]interface{}{}
+	if err := json.NewDecoder(w.Body).Decode(&bug); err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]interface{}{"id": 3.0, "summary": "slow"}; !reflect.DeepEqual(bug, expected) {
+		t.Errorf("expected %v, got %v", expected, bug)
+	}
+
+	for path, code := range map[string]int{
+		"/api/bugs/42":                  http.StatusNotFound,
+		"/api/bugs/nope":                http.StatusBadRequest,
+		"/api/teams/Nope":               http.StatusNotFound,
+		"/api/teams/Networking?limit=x": http.StatusBadRequest,
+		"/api?filter=nope":              http.StatusBadRequest,
+	} {
+		if w := get(path, ""); w.Code != code {
+			t.Errorf("%s: expected %d, got %d", path, code, w.Code)
+		}
+	}
+
+	w = get("/api", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected the full api with an etag, got %d %q", w.Code, etag)
+	}
+	if w := get("/api", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected an unchanged response to be 304, got %d", w.Code)
+	}
+	if w := get("/api?severity=low", etag); w.Code != http.StatusOK {
+		t.Errorf("expected a different response to be 200, got %d", w.Code)
+	}
+}
+
+func TestCoverageMonths(t *testing.T) {
+	handler := GetCoverageHandler(&bugs.BugData{})
+	for _, months := range []string{"0", "7", "1200"} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/coverage?months="+months, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("months=%s: expected %d, got %d", months, http.StatusBadRequest, w.Code)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+)
+
+const (
+	defaultCoverageMonths   = 6
+	defaultCoverageExamples = 5
+	// how long the search for unused components is reused
+	unusedCacheTTL = time.Hour
+)
+
+var (
+	// coverageMonths are the months which can be asked for. Each one is a
+	// search of every bug changed in that time, so they are not left open.
+	coverageMonths = sets.NewInt(1, 3, 6, 12)
+)
+
+// Coverage is what /coverage serves
+type Coverage struct {
+	Unowned []bugs.UnownedComponent `json:"unowned"`
+	Unused  []bugs.UnusedComponent  `json:"unused"`
+	// Months is how long the unused components have not had a bug change
+	Months int `json:"months"`
+}
+
+type unusedResult struct {
+	at     time.Time
+	unused []bugs.UnusedComponent
+}
+
+func intParam(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("%s must be a number >= 0, got %q", name, value)
+	}
+	return i, nil
+}
+
+// GetCoverageHandler serves /coverage?months=&examples=, the components with
+// open bugs no team owns and the components teams own which no bug changed in
+// months, one of coverageMonths. Finding unused components searches every bug
+// changed in that time, so the result is reused for an hour.
+func GetCoverageHandler(bugData *bugs.BugData) http.HandlerFunc {
+	lock := sync.Mutex{}
+	cache := map[int]unusedResult{}
+	return func(w http.ResponseWriter, r *http.Request) {
+		months, err := intParam(r, "months", defaultCoverageMonths)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !coverageMonths.Has(months) {
+			http.Error(w, fmt.Sprintf("months must be one of %v, got %d", coverageMonths.List(), months), http.StatusBadRequest)
+			return
+		}
+		examples, err := intParam(r, "examples", defaultCoverageExamples)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		lock.Lock()
+		result, ok := cache[months]
+		if !ok || time.Since(result.at) > unusedCacheTTL {
+			now := time.Now()
+			unused, err := bugData.UnusedComponents(now.AddDate(0, -months, 0))
+			if err != nil {
+				lock.Unlock()
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			result = unusedResult{at: now, unused: unused}
+			cache[months] = result
+		}
+		lock.Unlock()
+
+		writeJSON(w, r, bugData, Coverage{
+			Unowned: bugData.UnownedComponents(examples),
+			Unused:  result.unused,
+			Months:  months,
+		})
+	}
+}
@@ -1,57 +1,39 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
-	"fmt"
-	"net/http"
 	"os"
-	"os/signal"
 	"path/filepath"
-	"syscall"
 
 	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/history"
+	"github.com/openshift/bugzilla-tools/pkg/httpserver"
 	"github.com/openshift/bugzilla-tools/pkg/metrics"
 	"github.com/openshift/bugzilla-tools/pkg/teams"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 )
 
-const (
-	port = "8000"
-)
-
-func GetAPIHandler(bugData *bugs.BugData) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		bugMap := bugData.GetTeamMap()
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		err := json.NewEncoder(w).Encode(bugMap)
-		if err != nil {
-			fmt.Errorf("Unable to encode: %v: %v", bugMap, err)
-		}
-	}
-}
-
-func serveHTTP(errs chan error, bugData *bugs.BugData) {
-	mux := http.NewServeMux()
-	mux.Handle("/api", GetAPIHandler(bugData))
-	mux.Handle("/metrics", promhttp.Handler())
-
-	listenAt := fmt.Sprintf(":%s", port)
-	srv := &http.Server{
-		Addr:    listenAt,
-		Handler: mux,
+func serveHTTP(server *httpserver.Server, orgData *teams.OrgData, bugData *bugs.BugData, historyStore *history.Store) {
+	server.Handle("/api", GetAPIHandler(bugData))
+	server.Handle("/api/", GetAPIResourceHandler(bugData))
+	server.Handle("/coverage", GetCoverageHandler(bugData))
+	if historyStore != nil {
+		server.Handle("/history", historyStore.Handler())
 	}
-
-	go func() {
-		errs <- srv.ListenAndServe()
-	}()
+	server.Handle("/orgdata/health", orgData.HealthHandler())
+	server.ReadyWhen("orgdata", orgData.Loaded)
+	server.ReadyWhen("bugs", bugData.Loaded)
 }
 
 func doMain(cmd *cobra.Command, _ []string) error {
 	errs := make(chan error, 1)
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	server, err := httpserver.New(cmd)
+	if err != nil {
+		return err
+	}
+	// answer the probes while the data loads
+	server.Start(errs)
 
 	orgData, err := teams.GetOrgData(cmd)
 	if err != nil {
@@ -65,18 +47,18 @@ func doMain(cmd *cobra.Command, _ []string) error {
 	}
 	bugData.Reconciler(errs)
 
-	serveHTTP(errs, bugData)
+	historyStore, err := history.Setup(cmd, errs, bugData)
+	if err != nil {
+		return err
+	}
 
-	metrics.Setup(errs, bugData)
+	serveHTTP(server, orgData, bugData, historyStore)
 
-	select {
-	case <-stop:
-		fmt.Println("Sutting down...")
-		return nil
-	case err := <-errs:
-		fmt.Println("Server error:", err.Error())
+	if err := metrics.Setup(cmd, errs, bugData); err != nil {
 		return err
 	}
+
+	return server.Wait(errs)
 }
 
 func main() {
@@ -86,6 +68,9 @@ func main() {
 	}
 	bugs.AddFlags(cmd)
 	teams.AddFlags(cmd)
+	history.AddFlags(cmd)
+	metrics.AddFlags(cmd)
+	httpserver.AddFlags(cmd, ":8000")
 	cmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
 	if err := cmd.Execute(); err != nil {
 		os.Exit(1)
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"time"
 
 	"github.com/openshift/bugzilla-tools/pkg/bugs"
 	"github.com/openshift/bugzilla-tools/pkg/teams"
@@ -18,7 +19,7 @@ func doMain(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
-	bugData, err := bugs.GetBugData(cmd, orgData)
+	bugData, err := bugs.GetReconciledBugData(cmd, orgData)
 	if err != nil {
 		return err
 	}
@@ -27,6 +28,13 @@ func doMain(cmd *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
+	if len(targets) == 0 {
+		rt, err := orgData.GetReleaseTargets(time.Now())
+		if err != nil {
+			return err
+		}
+		targets = append([]string{"---"}, rt.CurrentTargets...)
+	}
 	bugData = bugData.FilterByTargetRelease(targets)
 
 	severities, err := cmd.Flags().GetStringSlice("severity")
@@ -56,7 +64,7 @@ func main() {
 	}
 	bugs.AddFlags(cmd)
 	teams.AddFlags(cmd)
-	cmd.Flags().StringSlice("target-release", bugs.CurrentReleaseTargets, "target release to filter by")
+	cmd.Flags().StringSlice("target-release", []string{}, "target release to filter by, unset uses --- and the current release from the org data")
 	cmd.Flags().StringSlice("severity", []string{"medium", "high", "urgent", "unspecified"}, "severities to filter by")
 	cmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
 	if err := cmd.Execute(); err != nil {
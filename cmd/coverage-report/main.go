@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+const (
+	monthsFlagName   = "months"
+	examplesFlagName = "examples"
+)
+
+func doMain(cmd *cobra.Command, _ []string) error {
+	months, err := cmd.Flags().GetInt(monthsFlagName)
+	if err != nil {
+		return err
+	}
+	examples, err := cmd.Flags().GetInt(examplesFlagName)
+	if err != nil {
+		return err
+	}
+	orgData, err := teams.GetOrgData(cmd)
+	if err != nil {
+		return err
+	}
+	bugData, err := bugs.GetReconciledBugData(cmd, orgData)
+	if err != nil {
+		return err
+	}
+
+	unowned := bugData.UnownedComponents(examples)
+	fmt.Printf("Components with open bugs no team owns: %d\n", len(unowned))
+	for _, u := range unowned {
+		name := u.Component
+		if u.Subcomponent != "" {
+			name += "/" + u.Subcomponent
+		}
+		ids := make([]string, len(u.Examples))
+		for i, id := range u.Examples {
+			ids[i] = strconv.Itoa(id)
+		}
+		fmt.Printf("  %s: %d bugs, eg %s\n", name, u.Count, strings.Join(ids, ", "))
+	}
+
+	unused, err := bugData.UnusedComponents(time.Now().AddDate(0, -months, 0))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nComponents owned by a team without a bug changed in %d months: %d\n", months, len(unused))
+	for _, u := range unused {
+		name := u.Component
+		if u.Subcomponent != "" {
+			name += "/" + u.Subcomponent
+		}
+		fmt.Printf("  %s: %s\n", u.Team, name)
+	}
+	return nil
+}
+
+func main() {
+	cmd := &cobra.Command{
+		Use:   filepath.Base(os.Args[0]),
+		Short: "Report components with open bugs no team owns and components teams own which no bug uses",
+		RunE:  doMain,
+	}
+	bugs.AddFlags(cmd)
+	teams.AddFlags(cmd)
+	cmd.Flags().Int(monthsFlagName, 6, "Report components owned by a team which no bug, open or closed, changed in this many months")
+	cmd.Flags().Int(examplesFlagName, 5, "How many bugs to list for every unowned component")
+	cmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
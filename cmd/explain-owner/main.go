@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+func doMain(cmd *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("bug must be a number, got %q", args[0])
+	}
+	orgData, err := teams.GetOrgData(cmd)
+	if err != nil {
+		return err
+	}
+	client, err := bugs.BugzillaClient(cmd)
+	if err != nil {
+		return err
+	}
+	bug, err := client.GetBug(id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Bug %d: %s\n", bug.ID, bug.Summary)
+	for _, component := range bug.Component {
+		subcomponents := strings.Join(bug.SubComponent[component], ", ")
+		if subcomponents == "" {
+			subcomponents = "<none>"
+		}
+		fmt.Printf("  component %s, subcomponents: %s\n", component, subcomponents)
+	}
+
+	owner := orgData.ResolveOwner(bug)
+	if len(owner.Matches) == 0 {
+		fmt.Printf("No team owns any component of this bug, it belongs to %s\n", owner.Team)
+		return nil
+	}
+	fmt.Println("Matching teams, in the order they were considered:")
+	for _, match := range owner.Matches {
+		fmt.Printf("  %s\n", match)
+	}
+	fmt.Printf("Owner: %s, from %s\n", owner.Team, *owner.Winner)
+	if len(owner.Conflicts) > 0 {
+		fmt.Printf("Conflict: %s also matched by the %s rule, %s won because its component and subcomponent come first on the bug, or its name sorts first\n",
+			strings.Join(owner.Conflicts, ", "), owner.Winner.Rule, owner.Team)
+	}
+	return nil
+}
+
+func main() {
+	cmd := &cobra.Command{
+		Use:   filepath.Base(os.Args[0]) + " <bug>",
+		Short: "Explain which team owns a bug and why",
+		Long: `Explain which team owns a bug and why.
+
+Every component and subcomponent of the bug is matched against the org data.
+A team listing the subcomponent beats a team owning the whole component, which
+beats a team with !!DEFAULT!! subcomponents. Ties go to the component and
+subcomponent listed first on the bug, then to the team whose name sorts first.`,
+		Args: cobra.ExactArgs(1),
+		RunE: doMain,
+	}
+	bugs.AddFlags(cmd)
+	teams.AddFlags(cmd)
+	cmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -8,13 +8,12 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"os/signal"
 	"path/filepath"
-	"syscall"
 	//"time"
 
-	"github.com/openshift/bugzilla-tools/pkg/bugs"
 	"github.com/openshift/bugzilla-tools/pkg/eventlogger"
+	"github.com/openshift/bugzilla-tools/pkg/httpserver"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
 
 	"github.com/andygrunwald/go-jira"
 	"github.com/ghodss/yaml"
@@ -22,16 +21,12 @@ import (
 	jiraHelper "github.com/openshift/bugzilla-tools/pkg/jira"
 	"github.com/openshift/library-go/pkg/controller/factory"
 	"github.com/openshift/library-go/pkg/operator/events"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 )
 
 const (
-	port = "8002"
-)
-
-var (
-	jiraQuery = fmt.Sprintf(`issuetype = Epic AND FixVersion = "OpenShift %s" AND Priority not in (Unprioritized) AND ("OpenShift Planning" != no-feature OR "OpenShift Planning" is EMPTY) AND status != "Won't Fix / Obsolete" AND filter = "Filter - Non AOS Projects"`, bugs.CurrentReleaseMinor)
+	// jiraQueryFmt is filled in with the current x.y release
+	jiraQueryFmt = `issuetype = Epic AND FixVersion = "OpenShift %s" AND Priority not in (Unprioritized) AND ("OpenShift Planning" != no-feature OR "OpenShift Planning" is EMPTY) AND status != "Won't Fix / Obsolete" AND filter = "Filter - Non AOS Projects"`
 )
 
 type SnapshotData struct {
@@ -149,35 +144,30 @@ func GetSnapshotsHandler(cmd *cobra.Command) http.HandlerFunc {
 	}
 }
 
-func serveHTTP(errs chan error, cmd *cobra.Command, client *jira.Client) {
-	mux := http.NewServeMux()
-	mux.Handle("/diff", DiffHandler(cmd, client))
-	mux.Handle("/snapshots", GetSnapshotsHandler(cmd))
-	mux.Handle("/metrics", promhttp.Handler())
+func serveHTTP(server *httpserver.Server, cmd *cobra.Command, client *jira.Client, orgData *teams.OrgData) {
+	server.Handle("/diff", DiffHandler(cmd, client))
+	server.Handle("/snapshots", GetSnapshotsHandler(cmd))
+	server.Handle("/orgdata/health", orgData.HealthHandler())
+	server.ReadyWhen("orgdata", orgData.Loaded)
 
 	staticHandler := http.FileServer(http.Dir("./web/build/"))
-	mux.Handle("/", staticHandler)
-
-	listenAt := fmt.Sprintf(":%s", port)
-	srv := &http.Server{
-		Addr:    listenAt,
-		Handler: mux,
-	}
-
-	go func() {
-		errs <- srv.ListenAndServe()
-	}()
+	server.Handle("/", staticHandler)
 }
 
 type DataCollector struct {
 	jiraClient *jira.Client
+	orgData    *teams.OrgData
 	cmd        *cobra.Command
 }
 
 func (dc *DataCollector) sync(ctx context.Context, syncCtx factory.SyncContext) error {
 	client := dc.jiraClient
+	version, err := dc.orgData.Current().CurrentVersion()
+	if err != nil {
+		return err
+	}
 	// Get all of the issues
-	issues, err := jiraHelper.GetIssues(client, jiraQuery)
+	issues, err := jiraHelper.GetIssues(client, fmt.Sprintf(jiraQueryFmt, version))
 	if err != nil {
 		return err
 	}
@@ -191,45 +181,47 @@ func (dc *DataCollector) sync(ctx context.Context, syncCtx factory.SyncContext)
 	return nil
 }
 
-func CollectData(schedule []string, recorder events.Recorder, cmd *cobra.Command, jiraClient *jira.Client) factory.Controller {
+func CollectData(schedule []string, recorder events.Recorder, cmd *cobra.Command, jiraClient *jira.Client, orgData *teams.OrgData) factory.Controller {
 	d := &DataCollector{
 		cmd:        cmd,
 		jiraClient: jiraClient,
+		orgData:    orgData,
 	}
 	return factory.New().ResyncSchedule(schedule...).WithSync(d.sync).ToController("CollectJiraData", recorder)
 }
 
 func doMain(cmd *cobra.Command, _ []string) error {
 	errs := make(chan error, 1)
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	server, err := httpserver.New(cmd)
+	if err != nil {
+		return err
+	}
+	// answer the probes while the data loads
+	server.Start(errs)
 
 	client, err := jiraHelper.GetClient(cmd)
 	if err != nil {
 		return err
 	}
 
+	orgData, err := teams.GetOrgData(cmd)
+	if err != nil {
+		return err
+	}
+	orgData.Reconciler()
+
 	ctx := context.TODO()
 	schedule := []string{
 		//"CRON_TZ=America/New_York 0 1 * * 1-5",
 		"* * * * *",
 	}
 	recorder := eventlogger.NewRecorder("DataCollector")
-	collectData := CollectData(schedule, recorder, cmd, client)
+	collectData := CollectData(schedule, recorder, cmd, client, orgData)
 	go collectData.Run(ctx, 1)
 
-	serveHTTP(errs, cmd, client)
-	fmt.Printf("Serving at %s\n", port)
-
-	err = nil
-	select {
-	case <-stop:
-		fmt.Println("Sutting down...")
-	case outErr := <-errs:
-		fmt.Println("Failed to start server:", outErr.Error())
-		err = outErr
-	}
-	return err
+	serveHTTP(server, cmd, client, orgData)
+	return server.Wait(errs)
 }
 
 func main() {
@@ -239,6 +231,8 @@ func main() {
 	}
 	cmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
 	jiraHelper.AddFlags(cmd)
+	teams.AddFlags(cmd)
+	httpserver.AddFlags(cmd, ":8002")
 
 	if err := cmd.Execute(); err != nil {
 		os.Exit(1)
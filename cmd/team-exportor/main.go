@@ -6,67 +6,57 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"os/signal"
 	"path/filepath"
-	"syscall"
 
+	"github.com/openshift/bugzilla-tools/pkg/httpserver"
+	"github.com/openshift/bugzilla-tools/pkg/orghistory"
 	"github.com/openshift/bugzilla-tools/pkg/teams"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 )
 
-const (
-	port = "8000"
-)
-
 func GetTeamHandler(orgData *teams.OrgData) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		err := json.NewEncoder(w).Encode(orgData)
+		current := orgData.Current()
+		err := json.NewEncoder(w).Encode(current)
 		if err != nil {
-			fmt.Printf("Unable to encode: %v: %v\n", orgData, err)
+			fmt.Printf("Unable to encode: %v: %v\n", current, err)
 		}
 	}
 }
 
-func serveHTTP(errs chan error, orgData *teams.OrgData) {
-	mux := http.NewServeMux()
-	mux.Handle("/teams", GetTeamHandler(orgData))
-	mux.Handle("/metrics", promhttp.Handler())
-
-	listenAt := fmt.Sprintf(":%s", port)
-	srv := &http.Server{
-		Addr:    listenAt,
-		Handler: mux,
+func serveHTTP(server *httpserver.Server, orgData *teams.OrgData, historyStore *orghistory.Store) {
+	server.Handle("/teams", GetTeamHandler(orgData))
+	if historyStore != nil {
+		server.Handle("/teams/history", historyStore.HistoryHandler())
+		server.Handle("/teams/diff", historyStore.DiffHandler())
 	}
-
-	go func() {
-		errs <- srv.ListenAndServe()
-	}()
+	server.Handle("/orgdata/health", orgData.HealthHandler())
+	server.ReadyWhen("orgdata", orgData.Loaded)
 }
 
 func doMain(cmd *cobra.Command, _ []string) error {
 	errs := make(chan error, 1)
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
-	orgData, err := teams.GetOrgData(cmd)
+	server, err := httpserver.New(cmd)
 	if err != nil {
 		return err
 	}
-	orgData.Reconciler()
-
-	serveHTTP(errs, orgData)
-	fmt.Println("http server started.")
+	// answer the probes while the data loads
+	server.Start(errs)
 
-	select {
-	case <-stop:
-		fmt.Println("Shutting down...")
-		return nil
-	case err := <-errs:
-		fmt.Println("Failed to start server:", err.Error())
+	orgData, err := teams.GetOrgData(cmd)
+	if err != nil {
 		return err
 	}
+	historyStore, err := orghistory.Setup(cmd, orgData)
+	if err != nil {
+		return err
+	}
+	orgData.Reconciler()
+
+	serveHTTP(server, orgData, historyStore)
+	return server.Wait(errs)
 }
 
 func main() {
@@ -75,6 +65,8 @@ func main() {
 		RunE: doMain,
 	}
 	teams.AddFlags(cmd)
+	orghistory.AddFlags(cmd)
+	httpserver.AddFlags(cmd, ":8000")
 	cmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
 	if err := cmd.Execute(); err != nil {
 		os.Exit(1)
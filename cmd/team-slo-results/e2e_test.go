@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/fakebugzilla"
+	sloAPI "github.com/openshift/bugzilla-tools/pkg/slo/api"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+// TestMain serves --test-bug-data from a fake bugzilla
+func TestMain(m *testing.M) {
+	fakes := &fakebugzilla.TestClients{}
+	bugs.TestClient = fakes.Client
+	code := m.Run()
+	fakes.Close()
+	os.Exit(code)
+}
+
+func TestEndToEnd(t *testing.T) {
+	orgData := teams.OrgData{
+		Teams: map[string]teams.TeamInfo{
+			"Networking": {Name: "Networking", Components: []string{"Networking"}, MemberCount: 2},
+			"Etcd":       {Name: "Etcd", Components: []string{"Etcd"}, MemberCount: 1},
+		},
+		Releases: map[string]teams.ReleaseInfo{
+			"4.7": {Name: "4.7", Targets: []string{"4.7.0"}, Milestones: &teams.Milestones{GA: "2099-01-01"}},
+		},
+		SLO: map[string]sloAPI.Data{
+			sloAPI.Urgent:  {Count: 0},
+			sloAPI.Blocker: {Count: 0},
+			sloAPI.All:     {Count: 1, PerMember: true},
+			sloAPI.PMScore: {Count: 100},
+		},
+	}
+	orgSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewEncoder(w).Encode(orgData); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer orgSrv.Close()
+
+	cmd := &cobra.Command{}
+	bugs.AddFlags(cmd)
+	teams.AddFlags(cmd)
+	if err := cmd.Flags().Parse([]string{"--test-bug-data=testdata/bugs.yaml", "--org-data-url=" + orgSrv.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	orgInfo, err := teams.GetOrgData(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bugData, err := bugs.GetBugData(cmd, orgInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := getTeamSLOResults(cmd, orgInfo, bugData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	networking := results["Networking"]
+	if !networking.Failing {
+		t.Errorf("expected Networking to fail its SLOs")
+	}
+	expected := []sloAPI.Result{
+		{Name: sloAPI.Urgent, Current: 1, Obligation: 0},
+		{Name: sloAPI.Blocker, Current: 1, Obligation: 0},
+		{Name: sloAPI.PMScore, Current: 11, Obligation: 100},
+		{Name: sloAPI.CI, Current: 0, Obligation: 0},
+		{Name: sloAPI.All, Current: 2, Obligation: 2, PerMember: true},
+	}
+	if !reflect.DeepEqual(networking.Results, expected) {
+		t.Errorf("expected %#v, got %#v", expected, networking.Results)
+	}
+	if results["Etcd"].Failing {
+		t.Errorf("expected Etcd to meet its SLOs, got %#v", results["Etcd"])
+	}
+}
@@ -6,27 +6,24 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"os/signal"
 	"path/filepath"
-	"syscall"
+	"sync/atomic"
 	"time"
 
 	//"github.com/kr/pretty"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 
 	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/httpserver"
+	"github.com/openshift/bugzilla-tools/pkg/metrics"
 	"github.com/openshift/bugzilla-tools/pkg/slo"
 	sloAPI "github.com/openshift/bugzilla-tools/pkg/slo/api"
 	"github.com/openshift/bugzilla-tools/pkg/teams"
 )
 
-const (
-	port = "8001"
-)
-
-func getTeamSLOResults(cmd *cobra.Command, orgInfo *teams.OrgData, bugData *bugs.BugData) (sloAPI.TeamsResults, error) {
+func getTeamSLOResults(cmd *cobra.Command, current *teams.OrgData, bugData *bugs.BugData) (sloAPI.TeamsResults, error) {
 	bugMaps := slo.GetBugMaps(bugData)
+	orgInfo := current.Current()
 
 	currentVersion, err := orgInfo.CurrentVersion()
 	if err != nil {
@@ -41,14 +38,16 @@ func getTeamSLOResults(cmd *cobra.Command, orgInfo *teams.OrgData, bugData *bugs
 
 	teamsResults := make(sloAPI.TeamsResults, len(orgInfo.Teams))
 	for team, teamInfo := range orgInfo.Teams {
-		teamsResults[team] = slo.GetTeamResult(bugMaps, ciComponentMap, orgInfo, teamInfo)
+		teamsResults[team] = slo.GetTeamResult(bugMaps, ciComponentMap, &orgInfo, teamInfo)
 	}
 	return teamsResults, nil
 }
 
-func GetTeamHandler(data interface{}) http.HandlerFunc {
+// GetTeamHandler serves data, computed from bugs loaded at *updated
+func GetTeamHandler(data interface{}, updated *time.Time) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
+		bugs.SetAgeHeaders(w.Header(), *updated)
 		err := json.NewEncoder(w).Encode(data)
 		if err != nil {
 			fmt.Printf("Unable to encode: %v: %v", data, err)
@@ -56,29 +55,26 @@ func GetTeamHandler(data interface{}) http.HandlerFunc {
 	}
 }
 
-func serveHTTP(errs chan error, serveResults *sloAPI.TeamsResults) {
-	mux := http.NewServeMux()
-	mux.Handle("/teams", GetTeamHandler(serveResults))
-	mux.Handle("/metrics", promhttp.Handler())
+func serveHTTP(server *httpserver.Server, orgInfo *teams.OrgData, bugData *bugs.BugData, serveResults *sloAPI.TeamsResults, resultsUpdated *time.Time, resultsReady func() bool) {
+	server.Handle("/teams", GetTeamHandler(serveResults, resultsUpdated))
+	server.Handle("/orgdata/health", orgInfo.HealthHandler())
+	server.ReadyWhen("orgdata", orgInfo.Loaded)
+	server.ReadyWhen("bugs", bugData.Loaded)
+	server.ReadyWhen("results", resultsReady)
 
 	staticHandler := http.FileServer(http.Dir("./web/build/"))
-	mux.Handle("/", staticHandler)
-
-	listenAt := fmt.Sprintf(":%s", port)
-	srv := &http.Server{
-		Addr:    listenAt,
-		Handler: mux,
-	}
-
-	go func() {
-		errs <- srv.ListenAndServe()
-	}()
+	server.Handle("/", staticHandler)
 }
 
 func doMain(cmd *cobra.Command) error {
 	errs := make(chan error, 1)
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	server, err := httpserver.New(cmd)
+	if err != nil {
+		return err
+	}
+	// answer the probes while the data loads
+	server.Start(errs)
 
 	orgInfo, err := teams.GetOrgData(cmd)
 	if err != nil {
@@ -93,9 +89,13 @@ func doMain(cmd *cobra.Command) error {
 	bugData.Reconciler(errs)
 
 	serveResults := &sloAPI.TeamsResults{}
+	resultsUpdated := &time.Time{}
+	// set once the first results, from the snapshot or bugzilla, are served
+	var computed int32
 
 	go func() {
 		for {
+			updated := bugData.Updated()
 			teamsResults, err := getTeamSLOResults(cmd, orgInfo, bugData)
 			if err != nil {
 				errs <- err
@@ -107,22 +107,15 @@ func doMain(cmd *cobra.Command) error {
 				continue
 			}
 			*serveResults = teamsResults
+			metrics.UpdateSLOs(teamsResults)
+			*resultsUpdated = updated
+			atomic.StoreInt32(&computed, 1)
 			time.Sleep(10 * time.Minute)
 		}
 	}()
-	serveHTTP(errs, serveResults)
-
-	fmt.Println("http server started.")
-
-	err = nil
-	select {
-	case <-stop:
-		fmt.Println("Sutting down...")
-	case outErr := <-errs:
-		fmt.Println("Failed to start server:", outErr.Error())
-		err = outErr
-	}
-	return err
+	resultsReady := func() bool { return atomic.LoadInt32(&computed) == 1 }
+	serveHTTP(server, orgInfo, bugData, serveResults, resultsUpdated, resultsReady)
+	return server.Wait(errs)
 }
 
 func main() {
@@ -139,6 +132,7 @@ func main() {
 	cmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
 	bugs.AddFlags(cmd)
 	teams.AddFlags(cmd)
+	httpserver.AddFlags(cmd, ":8001")
 	if err := cmd.Execute(); err != nil {
 		os.Exit(1)
 	}
@@ -18,7 +18,7 @@ func doMain(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
-	bugData, err := bugs.GetBugData(cmd, orgData)
+	bugData, err := bugs.GetReconciledBugData(cmd, orgData)
 	if err != nil {
 		return err
 	}
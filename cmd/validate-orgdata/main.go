@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/eparis/bugzilla"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+const (
+	ignoreFlagName  = "ignore"
+	ignoreFlagUsage = "Kinds of problem to not report, eg NoLead,NoSlackChannel"
+
+	checkBugsFlagName  = "check-bugs"
+	checkBugsFlagUsage = "Also load the open bugs and report components and subcomponents no team owns. This needs bugzilla access"
+)
+
+func doMain(cmd *cobra.Command, _ []string) error {
+	ignoreList, err := cmd.Flags().GetStringSlice(ignoreFlagName)
+	if err != nil {
+		return err
+	}
+	ignore := sets.NewString(ignoreList...)
+	checkBugs, err := cmd.Flags().GetBool(checkBugsFlagName)
+	if err != nil {
+		return err
+	}
+
+	orgData, err := teams.GetOrgData(cmd)
+	if err != nil {
+		return err
+	}
+	problems := orgData.Validate()
+	if checkBugs {
+		bugData, err := bugs.GetReconciledBugData(cmd, orgData)
+		if err != nil {
+			return err
+		}
+		apiBugs := []*bugzilla.Bug{}
+		for _, bug := range bugData.GetBugs() {
+			apiBugs = append(apiBugs, bug.APIBug())
+		}
+		problems = append(problems, orgData.ValidateBugComponents(apiBugs)...)
+	}
+
+	if !ignore.Has(teams.UnknownSheetTeam) && !orgData.SheetChecked() {
+		fmt.Printf("Skipped %s: the member count sheet is only compared with --data-from-github and a --member-count-source other than %s\n", teams.UnknownSheetTeam, teams.OrgDataSource)
+	}
+
+	found := 0
+	for _, problem := range problems {
+		if ignore.Has(problem.Kind) {
+			continue
+		}
+		fmt.Println(problem)
+		found++
+	}
+	if found > 0 {
+		return fmt.Errorf("found %d problems in the org data", found)
+	}
+	fmt.Println("No problems found")
+	return nil
+}
+
+func main() {
+	cmd := &cobra.Command{
+		Use:   filepath.Base(os.Args[0]),
+		Short: "Check the org data for conflicting, missing or malformed teams and releases",
+		Long: `Check the org data for conflicting, missing or malformed teams and releases.
+
+Every problem is printed as <kind>: <message> and the command exits non-zero
+if any were found, so it can gate changes to the org data. The kinds are
+ComponentConflict, DefaultConflict, NoComponents, NoSlackChannel, NoLead,
+UnknownSheetTeam, BadRelease and, with --check-bugs, UnownedComponent.
+UnknownSheetTeam needs --data-from-github and a member count sheet or file,
+otherwise it is reported as skipped.
+
+Use --data-from-github to check the merged config files rather than what the
+team-exportor currently serves, and --member-count-source=org-data to skip the
+member count sheet when its keys are not available.`,
+		SilenceUsage: true,
+		RunE:         doMain,
+	}
+	bugs.AddFlags(cmd)
+	teams.AddFlags(cmd)
+	cmd.Flags().StringSlice(ignoreFlagName, nil, ignoreFlagUsage)
+	cmd.Flags().Bool(checkBugsFlagName, false, checkBugsFlagUsage)
+	cmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -2,12 +2,29 @@ package api
 
 import (
 	"github.com/eparis/bugzilla"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// BugPreconditions are checked against every bug returned by a BugAction's
+// query. A bug which matches any of them is skipped and not updated.
+type BugPreconditions struct {
+	// SkipFlags skips bugs with any of these flags, in the form "blocker+" or "blocker" for any status
+	SkipFlags []string `json:"skipFlags,omitempty"`
+	// SkipKeywords skips bugs with any of these keywords
+	SkipKeywords []string `json:"skipKeywords,omitempty"`
+	// SkipChangedWithin skips bugs which were changed less than this long ago, eg "24h"
+	SkipChangedWithin *metav1.Duration `json:"skipChangedWithin,omitempty"`
+}
+
 type BugAction struct {
 	Name        string             `json:"name"`
 	Description string             `json:"description"`
 	Default     bool               `json:"default"`
 	Query       bugzilla.Query     `json:"query"`
 	Update      bugzilla.BugUpdate `json:"update"`
+	// MaxBugs aborts the action without updating anything if the query returns more bugs. 0 means no limit.
+	MaxBugs       int               `json:"maxBugs,omitempty"`
+	Preconditions *BugPreconditions `json:"preconditions,omitempty"`
+	// Schedule is a list of cron schedules, eg "0 * * * *", on which the daemon runs the action
+	Schedule []string `json:"schedule,omitempty"`
 }
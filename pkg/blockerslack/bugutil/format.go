@@ -2,6 +2,7 @@ package bugutil
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
@@ -9,6 +10,21 @@ import (
 	"github.com/openshift/bugzilla-tools/pkg/bugs"
 )
 
+// MakeBugzillaLink returns a slack link to a bugzilla list of the bugs
+func MakeBugzillaLink(hrefText string, ids []int) string {
+	u, _ := url.Parse("https://bugzilla.redhat.com/buglist.cgi")
+	e := u.Query()
+	e.Add("f1", "bug_id")
+	e.Add("o1", "anyexact")
+	stringIds := make([]string, len(ids))
+	for i := range stringIds {
+		stringIds[i] = fmt.Sprintf("%d", ids[i])
+	}
+	e.Add("v1", strings.Join(stringIds, ","))
+	u.RawQuery = e.Encode()
+	return fmt.Sprintf("<%s|%s>", u.String(), hrefText)
+}
+
 func GetBugURL(b *bugs.Bug) string {
 	return fmt.Sprintf("<https://bugzilla.redhat.com/show_bug.cgi?id=%d|#%d>", b.ID, b.ID)
 }
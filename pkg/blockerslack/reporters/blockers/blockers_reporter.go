@@ -3,7 +3,6 @@ package blockers
 import (
 	"context"
 	"fmt"
-	"net/url"
 	"os"
 	"strings"
 
@@ -76,7 +75,7 @@ type triageResult struct {
 
 func getLinkMsg(hrefFmt, msgFmt, who string, bugs []int, args ...string) string {
 	hrefText := fmt.Sprintf(hrefFmt, len(bugs), who)
-	linkText := makeBugzillaLink(hrefText, bugs)
+	linkText := bugutil.MakeBugzillaLink(hrefText, bugs)
 	fmtArgs := []interface{}{linkText}
 	for _, arg := range args {
 		fmtArgs = append(fmtArgs, arg)
@@ -160,32 +159,32 @@ func (tr triageResult) getTeamMessages() []string {
 	}
 	totalCount := tr.totalCount
 	href := fmt.Sprintf("%d Bugs", totalCount)
-	link := makeBugzillaLink(href, tr.bugs)
+	link := bugutil.MakeBugzillaLink(href, tr.bugs)
 	allBugsMsg := fmt.Sprintf("%s Total", link)
 
 	blockerCount := len(tr.blockers)
 	href = fmt.Sprintf("%d Release Blockers", blockerCount)
-	blockersMsg := makeBugzillaLink(href, tr.blockerIDs)
+	blockersMsg := bugutil.MakeBugzillaLink(href, tr.blockerIDs)
 
 	proposedBlockerCount := len(tr.proposedBlockers)
 	href = fmt.Sprintf("%d Proposed Release Blockers", proposedBlockerCount)
-	proposedBlockersMsg := makeBugzillaLink(href, tr.proposedBlockerIDs)
+	proposedBlockersMsg := bugutil.MakeBugzillaLink(href, tr.proposedBlockerIDs)
 
 	needReviewedInSprint := len(tr.needReviewedInSprintIDs)
 	href = fmt.Sprintf("%d Bugs Not Reviewed In This Sprint", needReviewedInSprint)
-	upcomingMsg := makeBugzillaLink(href, tr.needReviewedInSprintIDs)
+	upcomingMsg := bugutil.MakeBugzillaLink(href, tr.needReviewedInSprintIDs)
 
 	triageCount := len(tr.needTriage)
 	href = fmt.Sprintf("%d Untriaged Bugs", triageCount)
-	triageMsg := makeBugzillaLink(href, tr.needTriageIDs)
+	triageMsg := bugutil.MakeBugzillaLink(href, tr.needTriageIDs)
 
 	postCount := len(tr.postIDs)
 	href = fmt.Sprintf("%d Bugs in \"POST\"", postCount)
-	postMsg := makeBugzillaLink(href, tr.postIDs)
+	postMsg := bugutil.MakeBugzillaLink(href, tr.postIDs)
 
 	nonLowCount := len(tr.nonLowIDs)
 	href = fmt.Sprintf("%d Bugs formerly known as blockers", nonLowCount)
-	nonLowMsg := makeBugzillaLink(href, tr.nonLowIDs)
+	nonLowMsg := bugutil.MakeBugzillaLink(href, tr.nonLowIDs)
 
 	lines := []string{
 		fmt.Sprintf("\n:bug: *Today's %s OCP Bug Report:* :bug:\n", tr.who),
@@ -212,7 +211,7 @@ func (tr triageResult) getTeamMessages() []string {
 		for _, keyword := range seriousKeywords {
 			if bugIDs, ok := tr.seriousKeywordsIDs[keyword]; ok {
 				href := fmt.Sprintf("%d Bugs with %s", len(bugIDs), keyword)
-				lines = append(lines, fmt.Sprintf("> %s", makeBugzillaLink(href, bugIDs)))
+				lines = append(lines, fmt.Sprintf("> %s", bugutil.MakeBugzillaLink(href, bugIDs)))
 			}
 		}
 	}
@@ -242,7 +241,7 @@ func triageBug(who string, bugs ...*bugs.Bug) triageResult {
 			}
 		}
 
-		if strings.Contains(bug.Whiteboard, "LifecycleStale") {
+		if bug.Stale() {
 			r.staleCount++
 			continue
 		}
@@ -287,12 +286,16 @@ func (c *BlockersReporter) sync(ctx context.Context, syncCtx factory.SyncContext
 	if c.config.Debug {
 		fmt.Println("Started sync()")
 	}
-	c.orgData.Reconcile()
+	if err := c.orgData.Reconcile(); err != nil {
+		fmt.Printf("Unable to reconcile org data, using the last loaded: %v\n", err)
+	}
 	if err := c.bugData.Reconcile(); err != nil {
 		return err
 	}
+	// the org data is also reconciled in the background by other controllers
+	orgData := c.orgData.Current()
 
-	peopleNotificationMap, teamNotificationMap := Report(ctx, c.orgData, c.bugData, syncCtx.Recorder(), &c.config)
+	peopleNotificationMap, teamNotificationMap := Report(ctx, &orgData, c.bugData, syncCtx.Recorder(), &c.config)
 
 	for person, results := range peopleNotificationMap {
 		messages := results.getPersonalMessages()
@@ -307,13 +310,13 @@ func (c *BlockersReporter) sync(ctx context.Context, syncCtx factory.SyncContext
 		}
 	}
 
-	notSentToTeam := sets.NewString(c.orgData.GetTeamNames()...)
+	notSentToTeam := sets.NewString(orgData.GetTeamNames()...)
 	sentToTeam := []string{}
 	for team, results := range teamNotificationMap {
 		if results.totalCount == 0 {
 			continue
 		}
-		teamInfo, ok := c.orgData.Teams[team]
+		teamInfo, ok := orgData.Teams[team]
 		if !ok {
 			syncCtx.Recorder().Warningf("Unable to find team data", "team %q not found", team)
 			continue
@@ -372,17 +375,3 @@ func Report(ctx context.Context, orgData *teams.OrgData, bugData *bugs.BugData,
 
 	return peopleNotificationMap, teamNotificationMap
 }
-
-func makeBugzillaLink(hrefText string, ids []int) string {
-	u, _ := url.Parse("https://bugzilla.redhat.com/buglist.cgi")
-	e := u.Query()
-	e.Add("f1", "bug_id")
-	e.Add("o1", "anyexact")
-	stringIds := make([]string, len(ids))
-	for i := range stringIds {
-		stringIds[i] = fmt.Sprintf("%d", ids[i])
-	}
-	e.Add("v1", strings.Join(stringIds, ","))
-	u.RawQuery = e.Encode()
-	return fmt.Sprintf("<%s|%s>", u.String(), hrefText)
-}
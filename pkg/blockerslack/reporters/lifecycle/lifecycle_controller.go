@@ -0,0 +1,379 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eparis/bugzilla"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	"github.com/openshift/bugzilla-tools/pkg/blockerslack/bugutil"
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/cache"
+	"github.com/openshift/bugzilla-tools/pkg/slack"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+const (
+	enabledFlagName   = "lifecycle"
+	enabledFlagDefVal = false
+	enabledFlagUsage  = "Mark bugs without activity LifecycleStale and close them if nothing happens after that"
+
+	dryRunFlagName   = "lifecycle-dry-run"
+	dryRunFlagDefVal = false
+	dryRunFlagUsage  = "Only report which bugs would be marked stale, closed or no longer stale without changing them"
+
+	staleDaysFlagName   = "lifecycle-stale-days"
+	staleDaysFlagDefVal = 30
+	staleDaysFlagUsage  = "Days without any change after which a bug is marked LifecycleStale, unless its team sets lifecycle.staleAfterDays"
+
+	closeDaysFlagName   = "lifecycle-close-days"
+	closeDaysFlagDefVal = 7
+	closeDaysFlagUsage  = "Days a bug stays LifecycleStale without any change before it is closed, unless its team sets lifecycle.closeAfterDays"
+
+	statusesFlagName  = "lifecycle-statuses"
+	statusesFlagUsage = "Only bugs in these statuses are marked stale and closed"
+
+	day = 24 * time.Hour
+
+	staleCommentFmt = "This bug hasn't had any activity in the last %d days. It has been marked LifecycleStale and will be closed as DEFERRED in %d days unless someone updates it. " +
+		"Any change, including a comment, removes the LifecycleStale marker. If this bug is still relevant please set its severity, priority and target release or add a comment explaining why it should stay open."
+	closeCommentFmt = "This bug has been LifecycleStale for %d days without any activity and is being closed as DEFERRED. If it is still relevant please reopen it and add a comment explaining why."
+)
+
+// Options are the command line settings of the controller. Teams can override
+// the thresholds with the lifecycle section of their org data.
+type Options struct {
+	Enabled   bool
+	DryRun    bool
+	StaleDays int
+	CloseDays int
+	// Statuses are the statuses of the bugs the controller walks
+	Statuses []string
+}
+
+func GetOptions(cmd *cobra.Command) (Options, error) {
+	o := Options{}
+	var err error
+	if o.Enabled, err = cmd.Flags().GetBool(enabledFlagName); err != nil {
+		return o, err
+	}
+	if o.DryRun, err = cmd.Flags().GetBool(dryRunFlagName); err != nil {
+		return o, err
+	}
+	if o.StaleDays, err = cmd.Flags().GetInt(staleDaysFlagName); err != nil {
+		return o, err
+	}
+	if o.CloseDays, err = cmd.Flags().GetInt(closeDaysFlagName); err != nil {
+		return o, err
+	}
+	if o.Statuses, err = cmd.Flags().GetStringSlice(statusesFlagName); err != nil {
+		return o, err
+	}
+	if len(o.Statuses) == 0 {
+		return o, fmt.Errorf("--%s must not be empty", statusesFlagName)
+	}
+	if o.StaleDays <= 0 || o.CloseDays <= 0 {
+		return o, fmt.Errorf("--%s and --%s must be more than 0", staleDaysFlagName, closeDaysFlagName)
+	}
+	return o, nil
+}
+
+func AddFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool(enabledFlagName, enabledFlagDefVal, enabledFlagUsage)
+	cmd.Flags().Bool(dryRunFlagName, dryRunFlagDefVal, dryRunFlagUsage)
+	cmd.Flags().Int(staleDaysFlagName, staleDaysFlagDefVal, staleDaysFlagUsage)
+	cmd.Flags().Int(closeDaysFlagName, closeDaysFlagDefVal, closeDaysFlagUsage)
+	cmd.Flags().StringSlice(statusesFlagName, statusesFlagDefVal, statusesFlagUsage)
+}
+
+// action is what the controller does to a bug
+type action string
+
+const (
+	markStale   action = "marked stale"
+	unmarkStale action = "unmarked stale"
+	closeBug    action = "closed as DEFERRED"
+)
+
+var (
+	statusesFlagDefVal = []string{"NEW", "ASSIGNED"}
+
+	// the order actions are reported in
+	actions = []action{markStale, closeBug, unmarkStale}
+)
+
+type LifecycleController struct {
+	options Options
+
+	bugData     *bugs.BugData
+	orgData     *teams.OrgData
+	client      bugzilla.Client
+	history     cache.BugzillaClient
+	slackClient slack.ChannelClient
+	now         func() time.Time
+}
+
+// NewLifecycleController returns a controller which walks every bug in one of
+// the Statuses which is owned by a team:
+//
+//   - a bug which has not changed in StaleDays is marked LifecycleStale and
+//     gets a comment saying it will be closed
+//   - a stale bug which changed after it was marked is no longer stale
+//   - a stale bug which did not change for CloseDays is closed as DEFERRED
+//
+// Bugs with a blocker+ or blocker? flag are left alone, as are the bugs of
+// teams which disable the lifecycle. A summary of every change is sent to the
+// team's slack channel.
+func NewLifecycleController(schedule []string, options Options, bugData *bugs.BugData, orgData *teams.OrgData, slackClient slack.ChannelClient, recorder events.Recorder) factory.Controller {
+	c := newLifecycleController(options, bugData, orgData, slackClient)
+	return factory.New().WithSync(c.sync).ResyncSchedule(schedule...).ToController("LifecycleController", recorder)
+}
+
+func newLifecycleController(options Options, bugData *bugs.BugData, orgData *teams.OrgData, slackClient slack.ChannelClient) *LifecycleController {
+	return &LifecycleController{
+		options:     options,
+		bugData:     bugData,
+		orgData:     orgData,
+		client:      bugData.Client(),
+		history:     cache.NewCachedBugzillaClient(bugData.Client()),
+		slackClient: slackClient,
+		now:         time.Now,
+	}
+}
+
+// thresholds returns how long a team's bugs may go without a change before
+// they are marked stale, and then closed. ok is false if the team opted out.
+func (c *LifecycleController) thresholds(orgData *teams.OrgData, team string) (staleDays, closeDays int, ok bool) {
+	staleDays, closeDays = c.options.StaleDays, c.options.CloseDays
+	teamInfo, found := orgData.Teams[team]
+	if !found || teamInfo.Lifecycle == nil {
+		return staleDays, closeDays, true
+	}
+	if teamInfo.Lifecycle.Disabled {
+		return 0, 0, false
+	}
+	if teamInfo.Lifecycle.StaleAfterDays > 0 {
+		staleDays = teamInfo.Lifecycle.StaleAfterDays
+	}
+	if teamInfo.Lifecycle.CloseAfterDays > 0 {
+		closeDays = teamInfo.Lifecycle.CloseAfterDays
+	}
+	return staleDays, closeDays, true
+}
+
+// markedAt returns when LifecycleStale was last added to the whiteboard. If
+// the history does not say, the marker is treated as the last change.
+func (c *LifecycleController) markedAt(bug *bugs.Bug, lastChange time.Time) (time.Time, error) {
+	history, err := c.history.GetCachedBugHistory(bug.ID, bug.LastChangeTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+	marked := time.Time{}
+	for _, h := range history {
+		for _, change := range h.Changes {
+			if change.FieldName != "whiteboard" || !strings.Contains(change.Added, bugs.LifecycleStale) {
+				continue
+			}
+			when, err := bugs.ParseTime(h.When)
+			if err != nil {
+				return time.Time{}, err
+			}
+			if when.After(marked) {
+				marked = when
+			}
+		}
+	}
+	if marked.IsZero() {
+		return lastChange, nil
+	}
+	return marked, nil
+}
+
+// decide returns what should happen to the bug, if anything
+func (c *LifecycleController) decide(bug *bugs.Bug, staleDays, closeDays int, now time.Time) (action, error) {
+	if bug.Blocker() || bug.BlockerRequested() {
+		return "", nil
+	}
+	lastChange, err := bugs.ParseTime(bug.LastChangeTime)
+	if err != nil {
+		return "", fmt.Errorf("bug %d: %v", bug.ID, err)
+	}
+	if !bug.Stale() {
+		if now.Sub(lastChange) >= time.Duration(staleDays)*day {
+			return markStale, nil
+		}
+		return "", nil
+	}
+	marked, err := c.markedAt(bug, lastChange)
+	if err != nil {
+		return "", fmt.Errorf("bug %d: %v", bug.ID, err)
+	}
+	if lastChange.After(marked) {
+		return unmarkStale, nil
+	}
+	if now.Sub(marked) >= time.Duration(closeDays)*day {
+		return closeBug, nil
+	}
+	return "", nil
+}
+
+// withoutStale removes the LifecycleStale marker from a whiteboard.
+func withoutStale(whiteboard string) string {
+	out := strings.Join(strings.Fields(strings.ReplaceAll(whiteboard, bugs.LifecycleStale, "")), " ")
+	if out == "" {
+		// An empty whiteboard is dropped from the update, bugzilla trims
+		// the space so this clears it
+		return " "
+	}
+	return out
+}
+
+func bugUpdate(bug *bugs.Bug, a action, staleDays, closeDays int) bugzilla.BugUpdate {
+	switch a {
+	case markStale:
+		return bugzilla.BugUpdate{
+			Whiteboard: strings.TrimSpace(bug.Whiteboard + " " + bugs.LifecycleStale),
+			Comment:    &bugzilla.BugComment{Body: fmt.Sprintf(staleCommentFmt, staleDays, closeDays)},
+		}
+	case unmarkStale:
+		return bugzilla.BugUpdate{
+			Whiteboard:  withoutStale(bug.Whiteboard),
+			MinorUpdate: true,
+		}
+	default:
+		return bugzilla.BugUpdate{
+			Status:     "CLOSED",
+			Resolution: "DEFERRED",
+			Comment:    &bugzilla.BugComment{Body: fmt.Sprintf(closeCommentFmt, closeDays)},
+		}
+	}
+}
+
+// teamResult is the bugs of a team each action was taken on
+type teamResult map[action][]int
+
+func (r teamResult) message(team string, dryRun bool) string {
+	prefix := ""
+	if dryRun {
+		prefix = "[dry-run] "
+	}
+	lines := []string{fmt.Sprintf("%s:hourglass: *%s bug lifecycle:*", prefix, team)}
+	for _, a := range actions {
+		ids := r[a]
+		if len(ids) == 0 {
+			continue
+		}
+		href := bugutil.BugCountPlural(len(ids), true)
+		verb := "were"
+		switch {
+		case dryRun:
+			verb = "would be"
+		case len(ids) == 1:
+			verb = "was"
+		}
+		lines = append(lines, fmt.Sprintf("> %s %s %s", bugutil.MakeBugzillaLink(href, ids), verb, a))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sync marks, unmarks and closes the bugs of every team. Only failing to load
+// the bugs is returned as an error, a bug which can not be handled is reported
+// as an event and left for the next scheduled run: an error would make the
+// controller retry right away instead.
+func (c *LifecycleController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	// The org data is shared with the blockers reporter, which only reloads
+	// it when it runs, so reload it here too
+	if err := c.orgData.Reconcile(); err != nil {
+		klog.Warningf("Unable to reconcile org data, using the last loaded: %v", err)
+	}
+	if err := c.bugData.Reconcile(); err != nil {
+		return err
+	}
+	// the org data is also reconciled in the background by other controllers
+	orgData := c.orgData.Current()
+	now := c.now()
+	statuses := sets.NewString(c.options.Statuses...)
+
+	results := map[string]teamResult{}
+	for team, teamBugs := range c.bugData.GetTeamMap() {
+		// Nobody would be told about changes to bugs no team owns
+		if team == "unknown" {
+			continue
+		}
+		staleDays, closeDays, ok := c.thresholds(&orgData, team)
+		if !ok {
+			continue
+		}
+		for _, bug := range teamBugs {
+			if !statuses.Has(bug.Status) {
+				continue
+			}
+			a, err := c.decide(bug, staleDays, closeDays, now)
+			if err != nil {
+				syncCtx.Recorder().Warningf("HistoryFailed", "Unable to decide what to do with %v", err)
+				continue
+			}
+			if a == "" {
+				continue
+			}
+			if c.options.DryRun {
+				klog.Infof("Would have %s bug %d", a, bug.ID)
+			} else {
+				klog.Infof("Bug %d %s", bug.ID, a)
+				if err := c.client.UpdateBug(bug.ID, bugUpdate(bug, a, staleDays, closeDays)); err != nil {
+					syncCtx.Recorder().Warningf("UpdateFailed", "Bug %d could not be %s: %v", bug.ID, a, err)
+					continue
+				}
+			}
+			if results[team] == nil {
+				results[team] = teamResult{}
+			}
+			results[team][a] = append(results[team][a], bug.ID)
+		}
+	}
+
+	summary := []string{}
+	for _, team := range sortedTeams(results) {
+		result := results[team]
+		for _, ids := range result {
+			sort.Ints(ids)
+		}
+		message := result.message(team, c.options.DryRun)
+		summary = append(summary, message)
+		slackChan := ""
+		if teamInfo, ok := orgData.Teams[team]; ok {
+			slackChan = teamInfo.SlackChan
+		}
+		// Don't tell teams about changes which were not made
+		if c.options.DryRun || slackChan == "" {
+			continue
+		}
+		if err := c.slackClient.MessageChannel(slackChan, message); err != nil {
+			syncCtx.Recorder().Warningf("DeliveryFailed", "Failed to deliver lifecycle summary to channel %q: %v", slackChan, err)
+		}
+	}
+	if len(summary) == 0 {
+		summary = append(summary, "No bug lifecycle changes")
+	}
+	if err := c.slackClient.MessageDebug(strings.Join(summary, "\n\n")); err != nil {
+		syncCtx.Recorder().Warningf("DeliveryFailed", "Failed to deliver lifecycle summary to debug channel: %v", err)
+	}
+	return nil
+}
+
+func sortedTeams(results map[string]teamResult) []string {
+	out := make([]string, 0, len(results))
+	for team := range results {
+		out = append(out, team)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,169 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eparis/bugzilla"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	"github.com/openshift/bugzilla-tools/pkg/eventlogger"
+	"github.com/openshift/bugzilla-tools/pkg/fakebugzilla"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+// TestMain serves --test-bug-data from a fake bugzilla
+func TestMain(m *testing.M) {
+	fakes := &fakebugzilla.TestClients{}
+	bugs.TestClient = fakes.Client
+	code := m.Run()
+	fakes.Close()
+	os.Exit(code)
+}
+
+type fakeSlack struct {
+	channels map[string][]string
+	debug    []string
+}
+
+func (f *fakeSlack) MessageChannel(channel, message string) error {
+	f.channels[channel] = append(f.channels[channel], message)
+	return nil
+}
+
+func (f *fakeSlack) MessageDebug(message string) error {
+	f.debug = append(f.debug, message)
+	return nil
+}
+
+func (f *fakeSlack) MessageEmail(email, message string) error { return nil }
+
+func (f *fakeSlack) SetEmailMap(map[string]string) {}
+
+func TestSync(t *testing.T) {
+	orgData := &teams.OrgData{Teams: map[string]teams.TeamInfo{
+		"Networking": {Name: "Networking", Components: []string{"Networking"}, SlackChan: "#networking"},
+		"Storage":    {Name: "Storage", Components: []string{"Storage"}, Lifecycle: &teams.LifecycleConfig{Disabled: true}},
+		"Etcd":       {Name: "Etcd", Components: []string{"Etcd"}, Lifecycle: &teams.LifecycleConfig{StaleAfterDays: 200}},
+	}}
+
+	for _, dryRun := range []bool{false, true} {
+		cmd := &cobra.Command{}
+		bugs.AddFlags(cmd)
+		if err := cmd.Flags().Parse([]string{"--test-bug-data=testdata/bugs.yaml"}); err != nil {
+			t.Fatal(err)
+		}
+		bugData, err := bugs.GetBugData(cmd, orgData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slackClient := &fakeSlack{channels: map[string][]string{}}
+		c := newLifecycleController(Options{DryRun: dryRun, StaleDays: 30, CloseDays: 7, Statuses: statusesFlagDefVal}, bugData, orgData, slackClient)
+		c.now = func() time.Time { return time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC) }
+		recorder := eventlogger.NewRecorder("Lifecycle")
+		if err := c.sync(context.Background(), factory.NewSyncContext("LifecycleController", recorder)); err != nil {
+			t.Fatal(err)
+		}
+
+		client := bugData.Client()
+		expected := map[int][2]string{
+			1: {"NEW", "LifecycleStale"},
+			2: {"CLOSED", "LifecycleStale"},
+			3: {"NEW", "foo"},
+			4: {"NEW", ""},
+			5: {"NEW", ""},
+			6: {"NEW", ""},
+			7: {"NEW", ""},
+			8: {"NEW", "LifecycleStale"},
+			// not NEW or ASSIGNED
+			9: {"POST", ""},
+			// not owned by any team
+			10: {"NEW", ""},
+		}
+		if dryRun {
+			expected[1] = [2]string{"NEW", ""}
+			expected[2] = [2]string{"NEW", "LifecycleStale"}
+			expected[3] = [2]string{"NEW", "foo LifecycleStale"}
+		}
+		for id, e := range expected {
+			bug, err := client.GetBug(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bug.Status != e[0] || strings.TrimSpace(bug.Whiteboard) != e[1] {
+				t.Errorf("dry run %v: bug %d: expected %s %q, got %s %q", dryRun, id, e[0], e[1], bug.Status, bug.Whiteboard)
+			}
+		}
+		if !dryRun {
+			if bug, _ := client.GetBug(2); bug.Resolution != "DEFERRED" {
+				t.Errorf("expected bug 2 to be DEFERRED, got %q", bug.Resolution)
+			}
+			if comments, _ := client.GetBugComments(1); len(comments) != 1 || !strings.Contains(comments[0].Text, "closed as DEFERRED in 7 days") {
+				t.Errorf("expected bug 1 to be told it is stale, got %v", comments)
+			}
+		}
+
+		team := strings.Join(slackClient.channels["#networking"], "\n")
+		if dryRun && team != "" {
+			t.Errorf("dry run should not message teams, got:\n%s", team)
+		}
+		if !dryRun {
+			for _, s := range []string{"1 Bug> was marked stale", "was closed as DEFERRED", "was unmarked stale"} {
+				if !strings.Contains(team, s) {
+					t.Errorf("expected the team summary to contain %q, got:\n%s", s, team)
+				}
+			}
+		}
+		if len(slackClient.debug) != 1 || dryRun != strings.Contains(slackClient.debug[0], "[dry-run]") {
+			t.Errorf("dry run %v: unexpected debug messages: %v", dryRun, slackClient.debug)
+		}
+	}
+}
+
+// failingClient fails every update
+type failingClient struct {
+	bugzilla.Client
+}
+
+func (f failingClient) UpdateBug(id int, update bugzilla.BugUpdate) error {
+	return fmt.Errorf("response code 500 not 200")
+}
+
+func TestSyncReportsFailedBugs(t *testing.T) {
+	orgData := &teams.OrgData{Teams: map[string]teams.TeamInfo{
+		"Networking": {Name: "Networking", Components: []string{"Networking"}},
+	}}
+	cmd := &cobra.Command{}
+	bugs.AddFlags(cmd)
+	if err := cmd.Flags().Parse([]string{"--test-bug-data=testdata/bugs.yaml"}); err != nil {
+		t.Fatal(err)
+	}
+	bugData, err := bugs.GetBugData(cmd, orgData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newLifecycleController(Options{StaleDays: 30, CloseDays: 7, Statuses: statusesFlagDefVal}, bugData, orgData, &fakeSlack{channels: map[string][]string{}})
+	c.client = failingClient{c.client}
+	c.now = func() time.Time { return time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC) }
+	recorder := events.NewInMemoryRecorder("Lifecycle")
+	// an error would be retried right away instead of at the next schedule
+	if err := c.sync(context.Background(), factory.NewSyncContext("LifecycleController", recorder)); err != nil {
+		t.Errorf("expected failed bugs to only be reported, got %v", err)
+	}
+	failed := 0
+	for _, event := range recorder.Events() {
+		if event.Reason == "UpdateFailed" {
+			failed++
+		}
+	}
+	if failed == 0 {
+		t.Errorf("expected an UpdateFailed event for every bug, got %v", recorder.Events())
+	}
+}
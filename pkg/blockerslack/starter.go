@@ -8,6 +8,7 @@ import (
 
 	"github.com/openshift/bugzilla-tools/pkg/blockerslack/config"
 	"github.com/openshift/bugzilla-tools/pkg/blockerslack/reporters/blockers"
+	"github.com/openshift/bugzilla-tools/pkg/blockerslack/reporters/lifecycle"
 	"github.com/openshift/bugzilla-tools/pkg/bugs"
 	"github.com/openshift/bugzilla-tools/pkg/slack"
 	"github.com/openshift/bugzilla-tools/pkg/teams"
@@ -16,6 +17,11 @@ import (
 const bugzillaEndpoint = "https://bugzilla.redhat.com"
 
 func Run(ctx context.Context, cfg config.OperatorConfig, cmd *cobra.Command) error {
+	lifecycleOptions, err := lifecycle.GetOptions(cmd)
+	if err != nil {
+		return err
+	}
+
 	orgData, err := teams.GetOrgData(cmd)
 	if err != nil {
 		return err
@@ -48,6 +54,19 @@ func Run(ctx context.Context, cfg config.OperatorConfig, cmd *cobra.Command) err
 
 	go blockerReporter.Run(ctx, 1)
 
+	if lifecycleOptions.Enabled {
+		lifecycleSchedule := []string{
+			"0 6 * * 1-5",
+		}
+		if cfg.Debug {
+			// Never close bugs while debugging
+			lifecycleOptions.DryRun = true
+			lifecycleSchedule[0] = "* * * * *"
+		}
+		lifecycleController := lifecycle.NewLifecycleController(lifecycleSchedule, lifecycleOptions, bugData, orgData, slackChannelClient, recorder)
+		go lifecycleController.Run(ctx, 1)
+	}
+
 	<-ctx.Done()
 	return nil
 }
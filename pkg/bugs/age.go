@@ -0,0 +1,175 @@
+package bugs
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eparis/bugzilla"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/openshift/bugzilla-tools/pkg/cache"
+)
+
+const (
+	// how many bug histories are fetched at once
+	historyWorkers = 10
+)
+
+var (
+	// a change to any of these fields counts as triaging the bug
+	triageFields = map[string]bool{
+		"severity":       true,
+		"priority":       true,
+		"keywords":       true,
+		"flagtypes.name": true,
+	}
+)
+
+// BugAge is how long a bug has been around, in its current status and since
+// someone last triaged it.
+type BugAge struct {
+	ID          int
+	Team        string
+	Age         time.Duration
+	InStatus    time.Duration
+	SinceTriage time.Duration
+}
+
+// bugAge works out the age of a bug from its creation time and history.
+// Without a status or triage change the bug has been in its status, and
+// untriaged, since it was created.
+func bugAge(bug *Bug, history []bugzilla.History, now time.Time) (BugAge, error) {
+	created, err := ParseTime(bug.CreationTime)
+	if err != nil {
+		return BugAge{}, err
+	}
+	statusChanged, triaged := created, created
+	for _, h := range history {
+		when, err := ParseTime(h.When)
+		if err != nil {
+			return BugAge{}, err
+		}
+		for _, change := range h.Changes {
+			if change.FieldName == "status" && when.After(statusChanged) {
+				statusChanged = when
+			}
+			if triageFields[change.FieldName] && when.After(triaged) {
+				triaged = when
+			}
+		}
+	}
+	return BugAge{
+		ID:          bug.ID,
+		Age:         now.Sub(created),
+		InStatus:    now.Sub(statusChanged),
+		SinceTriage: now.Sub(triaged),
+	}, nil
+}
+
+// GetAges fetches the history of every bug and works out its age. Histories
+// are cached by the bug's last_change_time in the --bug-snapshot database, if
+// there is one, so only changed bugs are fetched again. Bugs whose history
+// can not be fetched are left out and their errors returned.
+func (bd *BugData) GetAges(now time.Time) ([]BugAge, error) {
+	client := cache.NewCachedBugzillaClient(bd.client)
+
+	type job struct {
+		bug  *Bug
+		team string
+	}
+	jobs := make(chan job)
+	lock := sync.Mutex{}
+	ages := []BugAge{}
+	errs := []error{}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < historyWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				history, err := client.GetCachedBugHistory(j.bug.ID, j.bug.LastChangeTime)
+				var age BugAge
+				if err == nil {
+					age, err = bugAge(j.bug, history, now)
+				}
+				lock.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					age.Team = j.team
+					ages = append(ages, age)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for team, teamBugs := range bd.GetTeamMap() {
+		for _, bug := range teamBugs {
+			jobs <- job{bug: bug, team: team}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(ages, func(i, j int) bool { return ages[i].ID < ages[j].ID })
+	return ages, utilerrors.NewAggregate(errs)
+}
+
+// AgePercentiles are percentiles of the ages of a set of bugs.
+type AgePercentiles struct {
+	Count       int
+	Age         map[float64]time.Duration
+	InStatus    map[float64]time.Duration
+	SinceTriage map[float64]time.Duration
+}
+
+// percentile returns the nearest rank percentile, p between 0 and 1, of
+// sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// TeamAgePercentiles returns the given percentiles, between 0 and 1, of the
+// ages of every team's bugs.
+func TeamAgePercentiles(ages []BugAge, percentiles []float64) map[string]AgePercentiles {
+	byTeam := map[string][3][]time.Duration{}
+	for _, age := range ages {
+		d := byTeam[age.Team]
+		d[0] = append(d[0], age.Age)
+		d[1] = append(d[1], age.InStatus)
+		d[2] = append(d[2], age.SinceTriage)
+		byTeam[age.Team] = d
+	}
+
+	out := map[string]AgePercentiles{}
+	for team, d := range byTeam {
+		for i := range d {
+			sort.Slice(d[i], func(a, b int) bool { return d[i][a] < d[i][b] })
+		}
+		result := AgePercentiles{
+			Count:       len(d[0]),
+			Age:         map[float64]time.Duration{},
+			InStatus:    map[float64]time.Duration{},
+			SinceTriage: map[float64]time.Duration{},
+		}
+		for _, p := range percentiles {
+			result.Age[p] = percentile(d[0], p)
+			result.InStatus[p] = percentile(d[1], p)
+			result.SinceTriage[p] = percentile(d[2], p)
+		}
+		out[team] = result
+	}
+	return out
+}
@@ -0,0 +1,62 @@
+package bugs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eparis/bugzilla"
+
+	"github.com/openshift/bugzilla-tools/pkg/fakebugzilla"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+func TestGetAges(t *testing.T) {
+	now := time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	newBug := func(id int, created string) bugzilla.Bug {
+		return bugzilla.Bug{
+			ID:             id,
+			Classification: "Red Hat",
+			Product:        "OpenShift Container Platform",
+			Component:      []string{"Networking"},
+			Status:         "NEW",
+			CreationTime:   created,
+			LastChangeTime: created,
+		}
+	}
+	srv := fakebugzilla.New(&fakebugzilla.Fixture{
+		Bugs: []bugzilla.Bug{newBug(1, "2020-10-01T00:00:00Z"), newBug(2, "2020-10-22T00:00:00Z")},
+		History: map[int][]bugzilla.History{
+			1: {
+				{When: "2020-10-11T00:00:00Z", Changes: []bugzilla.HistoryChange{{FieldName: "severity", Added: "high"}}},
+				{When: "2020-10-21T00:00:00Z", Changes: []bugzilla.HistoryChange{{FieldName: "status", Added: "NEW"}}},
+			},
+		},
+	})
+	defer srv.Close()
+
+	orgData := &teams.OrgData{Teams: map[string]teams.TeamInfo{
+		"Networking": {Name: "Networking", Components: []string{"Networking"}},
+	}}
+	bd := &BugData{client: srv.Client(), query: DefaultQueryConfig().Query(), orgData: orgData, fullResync: time.Hour}
+	if err := bd.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	ages, err := bd.GetAges(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []BugAge{
+		{ID: 1, Team: "Networking", Age: 31 * day, InStatus: 11 * day, SinceTriage: 21 * day},
+		{ID: 2, Team: "Networking", Age: 10 * day, InStatus: 10 * day, SinceTriage: 10 * day},
+	}
+	if !reflect.DeepEqual(ages, expected) {
+		t.Errorf("expected %v, got %v", expected, ages)
+	}
+
+	p := TeamAgePercentiles(ages, []float64{0.5, 1})["Networking"]
+	if p.Count != 2 || p.Age[0.5] != 10*day || p.Age[1] != 31*day || p.SinceTriage[1] != 21*day {
+		t.Errorf("unexpected percentiles %#v", p)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/openshift/bugzilla-tools/pkg/teams"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
 )
 
 const (
@@ -23,21 +24,30 @@ const (
 	bugDataFlagDefVal = ""
 	bugDataFlagUsage  = "Path to file containing test bug data"
 
+	bugzillaURLFlagName   = "bugzilla-url"
+	bugzillaURLFlagDefVal = "https://bugzilla.redhat.com"
+	bugzillaURLFlagUsage  = "URL of the bugzilla server to use"
+
+	fullResyncFlagName   = "bug-full-resync-interval"
+	fullResyncFlagDefVal = time.Hour
+	fullResyncFlagUsage  = "How often to reload every bug instead of only the ones changed since the last reconcile, 0 always reloads every bug"
+
+	// lastChangeTimeFormat is how a time is written in a search for bugs
+	// changed since then
+	lastChangeTimeFormat = "2006-01-02 15:04:05"
+
 	UpcomingSprint           = "UpcomingSprint"
 	ReviewedInSprintFlagName = "reviewed-in-sprint"
 
 	BlockerFlagName = "blocker"
 
+	// LifecycleStale is added to the whiteboard of bugs which have not
+	// changed in a long time and will be closed unless someone acts on them
+	LifecycleStale = "LifecycleStale"
+
 	FlagTrue      = "+"
 	FlagRequested = "?"
 	FlagFalse     = "-"
-
-	CurrentReleaseMinor = "4.7"
-)
-
-var (
-	CurrentRelease        = fmt.Sprintf("%s.0", CurrentReleaseMinor)
-	CurrentReleaseTargets = []string{"---", CurrentRelease}
 )
 
 type Bug bugzilla.Bug
@@ -94,6 +104,11 @@ func (b Bug) Untriaged() bool {
 	return false
 }
 
+// Stale is true if the whiteboard has the LifecycleStale marker
+func (b Bug) Stale() bool {
+	return strings.Contains(b.Whiteboard, LifecycleStale)
+}
+
 func (b Bug) LowPriorityAndSeverity() bool {
 	if b.Severity == "low" && b.Priority == "low" {
 		return true
@@ -173,11 +188,30 @@ func (b TeamMap) CountTargetRelease(team string, targets []string) int {
 
 type BugData struct {
 	sync.RWMutex
-	bugs    []*Bug
+	bugs []*Bug
+	// updated is when bugs were loaded from bugzilla
+	updated time.Time
+	// reconciled is true once a Reconcile succeeded, loading a snapshot
+	// does not count
+	reconciled bool
+
 	cmd     *cobra.Command
 	client  bugzilla.Client
 	query   bugzilla.Query
 	orgData *teams.OrgData
+
+	// reconcileLock serializes reconciles, which read and update the
+	// fields below it
+	reconcileLock sync.Mutex
+	fullResync    time.Duration
+	// highWater is the newest last_change_time of any loaded bug, zero
+	// until a full reconcile found one
+	highWater time.Time
+	lastFull  time.Time
+	// snapshots is true if every reconcile is saved with saveSnapshot
+	snapshots bool
+	// bugFilter is the --filter every reconciled bug has to match, if set
+	bugFilter *Filter
 }
 
 func (bd *BugData) clone() *BugData {
@@ -186,60 +220,54 @@ func (bd *BugData) clone() *BugData {
 	copy(newBugs, bugs)
 
 	bugData := &BugData{
-		cmd:     bd.cmd,
-		client:  bd.client,
-		query:   bd.query,
-		orgData: bd.orgData,
+		cmd:        bd.cmd,
+		client:     bd.client,
+		query:      bd.query,
+		orgData:    bd.orgData,
+		fullResync: bd.fullResync,
+		bugFilter:  bd.bugFilter,
 	}
-	bugData.set(newBugs)
+	bugData.update(newBugs, bd.Updated())
 	return bugData
 }
 
-func (orig *BugData) FilterByTargetRelease(sTargets []string) *BugData {
-	bd := orig.clone()
-	bugs := orig.GetBugs()
-
+// filter returns a BugData with only the bugs keep returns true for
+func (orig *BugData) filter(keep func(*Bug) bool) *BugData {
 	filtered := []*Bug{}
-	for i := range bugs {
-		bug := bugs[i]
-		if bug.HasTargetRelease(sTargets) {
+	for _, bug := range orig.GetBugs() {
+		if keep(bug) {
 			filtered = append(filtered, bug)
 		}
 	}
-	bd.set(filtered)
+	bd := &BugData{
+		cmd:        orig.cmd,
+		client:     orig.client,
+		query:      orig.query,
+		orgData:    orig.orgData,
+		fullResync: orig.fullResync,
+		bugFilter:  orig.bugFilter,
+	}
+	bd.update(filtered, orig.Updated())
 	return bd
 }
 
-func (orig *BugData) FilterBySeverity(sSeverities []string) *BugData {
-	bd := orig.clone()
-	bugs := bd.GetBugs()
+func (orig *BugData) FilterByTargetRelease(sTargets []string) *BugData {
+	return orig.filter(func(bug *Bug) bool {
+		return bug.HasTargetRelease(sTargets)
+	})
+}
 
+func (orig *BugData) FilterBySeverity(sSeverities []string) *BugData {
 	severities := sets.NewString(sSeverities...)
-	filtered := []*Bug{}
-	for i := range bugs {
-		bug := bugs[i]
-		if !severities.Has(bug.Severity) {
-			continue
-		}
-		filtered = append(filtered, bug)
-	}
-	bd.set(filtered)
-	return bd
+	return orig.filter(func(bug *Bug) bool {
+		return severities.Has(bug.Severity)
+	})
 }
 
 func (orig *BugData) FilterByFlag(name, status string) *BugData {
-	bd := orig.clone()
-	bugs := bd.GetBugs()
-
-	filtered := []*Bug{}
-	for i := range bugs {
-		bug := bugs[i]
-		if bug.Flag(name, status) {
-			filtered = append(filtered, bug)
-		}
-	}
-	bd.set(filtered)
-	return bd
+	return orig.filter(func(bug *Bug) bool {
+		return bug.Flag(name, status)
+	})
 }
 
 func (orig *BugData) FilterBlocker() *BugData {
@@ -263,6 +291,16 @@ func (bd *BugData) GetBugs() []*Bug {
 	return bd.bugs
 }
 
+// OrgData returns the org data used to find the team of every bug
+func (bd *BugData) OrgData() *teams.OrgData {
+	return bd.orgData
+}
+
+// Client returns the bugzilla client the bugs are loaded with
+func (bd *BugData) Client() bugzilla.Client {
+	return bd.client
+}
+
 func (bd *BugData) GetTeamMap() TeamMap {
 	bugs := bd.GetBugs()
 	teamMap := buildTeamMap(bugs, bd.orgData)
@@ -286,50 +324,180 @@ func (bd *BugData) set(bugs []*Bug) {
 	bd.bugs = bugs
 }
 
+// update replaces the bugs with ones loaded from bugzilla at the given time
+func (bd *BugData) update(bugs []*Bug, at time.Time) {
+	bd.Lock()
+	defer bd.Unlock()
+	bd.bugs = bugs
+	bd.updated = at
+}
+
+// Updated returns when the bugs were loaded from bugzilla. The bugs may come
+// from a snapshot taken by an earlier run.
+func (bd *BugData) Updated() time.Time {
+	bd.RLock()
+	defer bd.RUnlock()
+	return bd.updated
+}
+
+// Reconciled is true once the bugs were loaded from bugzilla by Reconcile.
+func (bd *BugData) Reconciled() bool {
+	bd.RLock()
+	defer bd.RUnlock()
+	return bd.reconciled
+}
+
+// Loaded is true once there are bugs to serve, either loaded from bugzilla
+// by Reconcile or from a snapshot.
+func (bd *BugData) Loaded() bool {
+	return !bd.Updated().IsZero()
+}
+
+// Age returns how long ago the bugs were loaded from bugzilla.
+func (bd *BugData) Age() time.Duration {
+	return time.Since(bd.Updated())
+}
+
+// Reconcile loads every bug the first time and whenever the full resync
+// interval passed. In between it only asks bugzilla for the bugs changed since
+// the newest change already loaded and merges them in. Bugs which no longer
+// match the query, for example because they were closed, are only dropped by
+// the next full reconcile.
 func (bd *BugData) Reconcile() error {
+	bd.reconcileLock.Lock()
+	defer bd.reconcileLock.Unlock()
+
+	var err error
+	start := time.Now()
+	reconcileType := "incremental"
+	if bd.highWater.IsZero() || time.Since(bd.lastFull) >= bd.fullResync {
+		reconcileType = "full"
+		err = bd.fullReconcile()
+	} else {
+		err = bd.incrementalReconcile()
+	}
+	reconcileDuration.WithLabelValues(reconcileType).Observe(time.Since(start).Seconds())
+	if err != nil {
+		reconcileErrors.WithLabelValues(reconcileType).Inc()
+		return err
+	}
+	reconcileLastSuccess.SetToCurrentTime()
+	loadedBugs.Set(float64(bd.Length()))
+	bd.Lock()
+	bd.reconciled = true
+	bd.Unlock()
+	bd.saveSnapshot()
+	return nil
+}
+
+func (bd *BugData) fullReconcile() error {
+	start := time.Now()
 	apibugs, err := bd.client.Search(bd.query)
 	if err != nil {
 		return err
 	}
-	bugs := make([]*Bug, len(apibugs))
+	bugs := make([]*Bug, 0, len(apibugs))
 	for i := range apibugs {
-		bugs[i] = (*Bug)(apibugs[i])
+		bug := (*Bug)(apibugs[i])
+		if bd.keep(bug) {
+			bugs = append(bugs, bug)
+		}
 	}
-	bd.set(bugs)
+	bd.update(bugs, start)
+	bd.lastFull = start
+	bd.highWater = newestChange(bugs, time.Time{})
 	return nil
 }
 
-type testClient struct {
-	path string
-}
+func (bd *BugData) incrementalReconcile() error {
+	start := time.Now()
+	query := bd.query
+	query.Advanced = append(append([]bugzilla.AdvancedQuery{}, bd.query.Advanced...), bugzilla.AdvancedQuery{
+		Field: "delta_ts",
+		Op:    "greaterthaneq",
+		Value: bd.highWater.UTC().Format(lastChangeTimeFormat),
+	})
+	apibugs, err := bd.client.Search(query)
+	if err != nil {
+		return err
+	}
+	changed := make([]*Bug, len(apibugs))
+	byID := map[int]*Bug{}
+	for i := range apibugs {
+		changed[i] = (*Bug)(apibugs[i])
+		byID[changed[i].ID] = changed[i]
+	}
 
-func (tc testClient) UpdateBug(_ int, _ bugzilla.BugUpdate) error {
+	// replace the changed bugs in place, dropping those which no longer
+	// match the filter, and add the new ones at the end
+	bugs := []*Bug{}
+	for _, bug := range bd.GetBugs() {
+		if newBug, ok := byID[bug.ID]; ok {
+			delete(byID, bug.ID)
+			bug = newBug
+		}
+		if bd.keep(bug) {
+			bugs = append(bugs, bug)
+		}
+	}
+	for _, bug := range changed {
+		if _, ok := byID[bug.ID]; ok && bd.keep(bug) {
+			bugs = append(bugs, bug)
+		}
+	}
+	bd.update(bugs, start)
+	bd.highWater = newestChange(changed, bd.highWater)
 	return nil
 }
-func (tc testClient) Search(_ bugzilla.Query) ([]*Bug, error) {
-	return []*Bug{}, nil
-}
-func (tc testClient) GetExternalBugPRsOnBug(_ int) ([]bugzilla.ExternalBug, error) {
-	return []bugzilla.ExternalBug{}, nil
+
+// keep returns true if the bug matches --filter
+func (bd *BugData) keep(bug *Bug) bool {
+	return bd.bugFilter == nil || bd.bugFilter.Match(bug, bd.orgData)
 }
-func (tc testClient) GetBug(_ int) (Bug, error) {
-	return Bug(bugzilla.Bug{}), nil
+
+// newestChange returns the newest last_change_time of the bugs, or since if
+// none of them changed after it.
+func newestChange(bugs []*Bug, since time.Time) time.Time {
+	newest := since
+	for _, bug := range bugs {
+		changed, err := ParseTime(bug.LastChangeTime)
+		if err != nil {
+			continue
+		}
+		if changed.After(newest) {
+			newest = changed
+		}
+	}
+	return newest
 }
-func (tc testClient) Endpoint() string {
-	return tc.path
+
+// ParseTime parses the timestamps bugzilla uses for fields like last_change_time
+func ParseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02 15:04:05 -0700 MST", value)
 }
-func (testClient) AddPullRequestAsExternalBug(_ int, _ string, _ string, _ int) (bool, error) {
-	return false, nil
+
+// TestClient returns the client used for --test-bug-data. Tests replace it
+// with fakebugzilla.TestClients to serve fixtures from a fake bugzilla, which
+// keeps the fake out of every binary.
+var TestClient = func(path string) (bugzilla.Client, error) {
+	return bugzilla.GetTestClient(path), nil
 }
 
 func BugzillaClient(cmd *cobra.Command) (bugzilla.Client, error) {
 	if testPath, err := cmd.Flags().GetString(bugDataFlagName); err != nil {
 		return nil, err
 	} else if testPath != "" {
-		return bugzilla.GetTestClient(testPath), nil
+		return TestClient(testPath)
 	}
 
-	endpoint := "https://bugzilla.redhat.com"
+	endpoint, err := cmd.Flags().GetString(bugzillaURLFlagName)
+	if err != nil {
+		return nil, err
+	}
 
 	keyFile, err := cmd.Flags().GetString(APIKeyFlagName)
 	dat, err := ioutil.ReadFile(keyFile)
@@ -351,23 +519,6 @@ func BugzillaClient(cmd *cobra.Command) (bugzilla.Client, error) {
 	return client, nil
 }
 
-func getAllOpenBugsQuery() bugzilla.Query {
-	return bugzilla.Query{
-		Classification: []string{"Red Hat"},
-		Product:        []string{"OpenShift Container Platform"},
-		Status:         []string{"NEW", "ASSIGNED", "POST", "ON_DEV", "MODIFIED"},
-		IncludeFields:  []string{"id", "summary", "status", "severity", "priority", "assigned_to", "target_release", "component", "sub_components", "keywords", "cf_pm_score", "flags"},
-		Advanced: []bugzilla.AdvancedQuery{
-			{
-				Field:  "component",
-				Op:     "equals",
-				Value:  "Documentation",
-				Negate: true,
-			},
-		},
-	}
-}
-
 func buildPeopleMap(bugs []*Bug) PeopleMap {
 	out := PeopleMap{}
 	for i := range bugs {
@@ -379,7 +530,9 @@ func buildPeopleMap(bugs []*Bug) PeopleMap {
 	return out
 }
 
-func buildTeamMap(bugs []*Bug, orgData *teams.OrgData) TeamMap {
+func buildTeamMap(bugs []*Bug, current *teams.OrgData) TeamMap {
+	// every bug is looked up in the same org data, even if it is reconciled
+	orgData := current.Current()
 	out := TeamMap{}
 	for _, team := range orgData.Teams {
 		out[team.Name] = []*Bug{}
@@ -401,8 +554,11 @@ func getBugzillaAccess(cmd *cobra.Command) (bugzilla.Client, bugzilla.Query, err
 	if err != nil {
 		return client, query, err
 	}
-	query = getAllOpenBugsQuery()
-	return client, query, nil
+	config, err := GetQueryConfig(cmd)
+	if err != nil {
+		return client, query, err
+	}
+	return client, config.Query(), nil
 }
 
 func (bd *BugData) Reconciler(errs chan error) {
@@ -412,7 +568,8 @@ func (bd *BugData) Reconciler(errs chan error) {
 				errs <- err
 				return
 			}
-			fmt.Printf("Successfully reconciled GetBugData. Teams:%d BugCount:%d\n", len(bd.orgData.Teams), len(bd.GetBugs()))
+			orgData := bd.orgData.Current()
+			fmt.Printf("Successfully reconciled GetBugData. Teams:%d BugCount:%d\n", len(orgData.Teams), len(bd.GetBugs()))
 			time.Sleep(time.Minute * 5)
 		}
 	}()
@@ -423,11 +580,35 @@ func GetBugData(cmd *cobra.Command, orgData *teams.OrgData) (*BugData, error) {
 	if err != nil {
 		return nil, err
 	}
+	fullResync, err := cmd.Flags().GetDuration(fullResyncFlagName)
+	if err != nil {
+		return nil, err
+	}
+	bugFilter, err := GetFilter(cmd)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := openSnapshots(cmd)
+	if err != nil {
+		return nil, err
+	}
 	bugData := &BugData{
-		cmd:     cmd,
-		client:  client,
-		query:   query,
-		orgData: orgData,
+		cmd:        cmd,
+		client:     client,
+		query:      query,
+		orgData:    orgData,
+		fullResync: fullResync,
+		snapshots:  snapshots,
+		bugFilter:  bugFilter,
+	}
+	if snapshots {
+		// Serve the snapshot, the caller's Reconcile or Reconciler brings
+		// it up to date.
+		if loaded, err := bugData.loadSnapshot(); err != nil {
+			klog.Warningf("Unable to load the bug snapshot: %v", err)
+		} else if loaded {
+			return bugData, nil
+		}
 	}
 	err = bugData.Reconcile()
 	if err != nil {
@@ -436,7 +617,29 @@ func GetBugData(cmd *cobra.Command, orgData *teams.OrgData) (*BugData, error) {
 	return bugData, nil
 }
 
+// GetReconciledBugData is GetBugData for commands which run once instead of
+// serving: bugs loaded from a snapshot are brought up to date with bugzilla
+// before they are returned, rather than by a Reconciler later.
+func GetReconciledBugData(cmd *cobra.Command, orgData *teams.OrgData) (*BugData, error) {
+	bugData, err := GetBugData(cmd, orgData)
+	if err != nil {
+		return nil, err
+	}
+	if !bugData.Reconciled() {
+		klog.Infof("Updating the bug snapshot from %v ago", bugData.Age().Round(time.Second))
+		if err := bugData.Reconcile(); err != nil {
+			return nil, err
+		}
+	}
+	return bugData, nil
+}
+
 func AddFlags(cmd *cobra.Command) {
 	cmd.Flags().String(bugDataFlagName, bugDataFlagDefVal, bugDataFlagUsage)
 	cmd.Flags().String(APIKeyFlagName, apiKeyFlagDefVal, apiKeyFlagUsage)
+	cmd.Flags().String(bugzillaURLFlagName, bugzillaURLFlagDefVal, bugzillaURLFlagUsage)
+	cmd.Flags().Duration(fullResyncFlagName, fullResyncFlagDefVal, fullResyncFlagUsage)
+	cmd.Flags().String(snapshotFlagName, snapshotFlagDefVal, snapshotFlagUsage)
+	cmd.Flags().String(filterFlagName, filterFlagDefVal, filterFlagUsage)
+	addQueryFlags(cmd)
 }
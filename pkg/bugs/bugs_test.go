@@ -0,0 +1,63 @@
+package bugs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eparis/bugzilla"
+
+	"github.com/openshift/bugzilla-tools/pkg/fakebugzilla"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+func TestIncrementalReconcile(t *testing.T) {
+	newBug := func(id int) bugzilla.Bug {
+		return bugzilla.Bug{
+			ID:             id,
+			Classification: "Red Hat",
+			Product:        "OpenShift Container Platform",
+			Component:      []string{"Networking"},
+			Status:         "NEW",
+			Severity:       "low",
+			LastChangeTime: "2020-10-01T00:00:00Z",
+		}
+	}
+	srv := fakebugzilla.New(&fakebugzilla.Fixture{Bugs: []bugzilla.Bug{newBug(1), newBug(2)}})
+	defer srv.Close()
+	client := srv.Client()
+
+	bd := &BugData{
+		client:     client,
+		query:      DefaultQueryConfig().Query(),
+		orgData:    &teams.OrgData{},
+		fullResync: time.Hour,
+	}
+	if err := bd.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	if bd.Length() != 2 || bd.highWater.IsZero() {
+		t.Fatalf("expected a full load of 2 bugs, got %d bugs changed up to %v", bd.Length(), bd.highWater)
+	}
+
+	if err := client.UpdateBug(1, bugzilla.BugUpdate{Severity: "urgent"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UpdateBug(2, bugzilla.BugUpdate{Status: "CLOSED"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bd.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	bugs := bd.GetBugs()
+	if len(bugs) != 2 || bugs[0].Severity != "urgent" || bugs[1].Status != "NEW" {
+		t.Errorf("expected only the changed open bug to be merged, got %#v %#v", bugs[0], bugs[1])
+	}
+
+	bd.fullResync = 0
+	if err := bd.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	if bd.Length() != 1 {
+		t.Errorf("expected the full resync to drop the closed bug, got %d bugs", bd.Length())
+	}
+}
@@ -0,0 +1,140 @@
+package bugs
+
+import (
+	"sort"
+	"time"
+
+	"github.com/eparis/bugzilla"
+)
+
+// UnownedComponent is a component, and subcomponent, with open bugs which no
+// team owns.
+type UnownedComponent struct {
+	Component    string `json:"component"`
+	Subcomponent string `json:"subcomponent,omitempty"`
+	Count        int    `json:"count"`
+	// Examples are the IDs of a few of the bugs, lowest first
+	Examples []int `json:"examples"`
+}
+
+// UnusedComponent is a component, or subcomponent, a team owns which no bug
+// has changed in a while.
+type UnusedComponent struct {
+	Team         string `json:"team"`
+	Component    string `json:"component"`
+	Subcomponent string `json:"subcomponent,omitempty"`
+}
+
+// componentPairs returns every component and subcomponent of the bug. A
+// component without subcomponents has the subcomponent "".
+func componentPairs(bug *Bug) [][2]string {
+	out := [][2]string{}
+	for _, component := range bug.Component {
+		subcomponents := bug.SubComponent[component]
+		if len(subcomponents) == 0 {
+			subcomponents = []string{""}
+		}
+		for _, subcomponent := range subcomponents {
+			out = append(out, [2]string{component, subcomponent})
+		}
+	}
+	return out
+}
+
+// UnownedComponents returns every component and subcomponent of the open bugs
+// no team owns, with the most bugs first, and up to `examples` of their bugs.
+func (bd *BugData) UnownedComponents(examples int) []UnownedComponent {
+	byPair := map[[2]string]*UnownedComponent{}
+	orgData := bd.orgData.Current()
+	bugs := append([]*Bug{}, bd.GetTeamMap()["unknown"]...)
+	sort.Slice(bugs, func(i, j int) bool { return bugs[i].ID < bugs[j].ID })
+	for _, bug := range bugs {
+		for _, pair := range componentPairs(bug) {
+			if orgData.GetTeamByComponent(pair[0], pair[1]) != nil {
+				continue
+			}
+			u, ok := byPair[pair]
+			if !ok {
+				u = &UnownedComponent{Component: pair[0], Subcomponent: pair[1], Examples: []int{}}
+				byPair[pair] = u
+			}
+			u.Count++
+			if len(u.Examples) < examples {
+				u.Examples = append(u.Examples, bug.ID)
+			}
+		}
+	}
+
+	out := make([]UnownedComponent, 0, len(byPair))
+	for _, u := range byPair {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		if out[i].Component != out[j].Component {
+			return out[i].Component < out[j].Component
+		}
+		return out[i].Subcomponent < out[j].Subcomponent
+	})
+	return out
+}
+
+// UnusedComponents searches for every bug, open or closed, changed since
+// `since` and returns the components, and listed subcomponents, of every team
+// which none of them are in. They are sorted by team and component.
+func (bd *BugData) UnusedComponents(since time.Time) ([]UnusedComponent, error) {
+	query := bugzilla.Query{
+		Classification: bd.query.Classification,
+		Product:        bd.query.Product,
+		IncludeFields:  []string{"id", "component", "sub_components", "last_change_time"},
+		Advanced: append(append([]bugzilla.AdvancedQuery{}, bd.query.Advanced...), bugzilla.AdvancedQuery{
+			Field: "delta_ts",
+			Op:    "greaterthaneq",
+			Value: since.UTC().Format(lastChangeTimeFormat),
+		}),
+	}
+	apibugs, err := bd.client.Search(query)
+	if err != nil {
+		return nil, err
+	}
+	components := map[string]bool{}
+	pairs := map[[2]string]bool{}
+	for _, apibug := range apibugs {
+		for _, pair := range componentPairs((*Bug)(apibug)) {
+			components[pair[0]] = true
+			pairs[pair] = true
+		}
+	}
+
+	out := []UnusedComponent{}
+	orgData := bd.orgData.Current()
+	for _, name := range orgData.GetTeamNames() {
+		team := orgData.Teams[name]
+		for _, component := range team.Components {
+			subcomponents, ok := team.Subcomponents[component]
+			if !ok || (len(subcomponents) == 1 && subcomponents[0] == "!!DEFAULT!!") {
+				if !components[component] {
+					out = append(out, UnusedComponent{Team: name, Component: component})
+				}
+				continue
+			}
+			for _, subcomponent := range subcomponents {
+				if !pairs[[2]string{component, subcomponent}] {
+					out = append(out, UnusedComponent{Team: name, Component: component, Subcomponent: subcomponent})
+				}
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Team != out[j].Team {
+			return out[i].Team < out[j].Team
+		}
+		if out[i].Component != out[j].Component {
+			return out[i].Component < out[j].Component
+		}
+		return out[i].Subcomponent < out[j].Subcomponent
+	})
+	return out, nil
+}
@@ -0,0 +1,75 @@
+package bugs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eparis/bugzilla"
+
+	"github.com/openshift/bugzilla-tools/pkg/fakebugzilla"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+func TestCoverage(t *testing.T) {
+	// components are "component" or "component/subcomponent"
+	newBug := func(id int, status, changed string, components ...string) bugzilla.Bug {
+		bug := bugzilla.Bug{
+			ID:             id,
+			Classification: "Red Hat",
+			Product:        "OpenShift Container Platform",
+			Status:         status,
+			LastChangeTime: changed,
+			SubComponent:   map[string][]string{},
+		}
+		for _, c := range components {
+			parts := strings.SplitN(c, "/", 2)
+			bug.Component = append(bug.Component, parts[0])
+			if len(parts) == 2 {
+				bug.SubComponent[parts[0]] = append(bug.SubComponent[parts[0]], parts[1])
+			}
+		}
+		return bug
+	}
+	srv := fakebugzilla.New(&fakebugzilla.Fixture{Bugs: []bugzilla.Bug{
+		newBug(1, "NEW", "2020-10-01T00:00:00Z", "Networking"),
+		newBug(2, "NEW", "2020-10-01T00:00:00Z", "Mystery"),
+		newBug(3, "NEW", "2020-10-01T00:00:00Z", "Mystery"),
+		newBug(4, "NEW", "2020-10-01T00:00:00Z", "Node/Other"),
+		newBug(5, "CLOSED", "2020-09-01T00:00:00Z", "Node/Kubelet"),
+		newBug(6, "CLOSED", "2019-01-01T00:00:00Z", "Node/CRI-O", "Storage"),
+	}})
+	defer srv.Close()
+
+	orgData := &teams.OrgData{Teams: map[string]teams.TeamInfo{
+		"Networking": {Name: "Networking", Components: []string{"Networking"}},
+		"Node":       {Name: "Node", Components: []string{"Node"}, Subcomponents: map[string][]string{"Node": {"Kubelet", "CRI-O"}}},
+		"Storage":    {Name: "Storage", Components: []string{"Storage"}},
+	}}
+	bd := &BugData{client: srv.Client(), query: DefaultQueryConfig().Query(), orgData: orgData, fullResync: time.Hour}
+	if err := bd.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+
+	unowned := bd.UnownedComponents(1)
+	expectedUnowned := []UnownedComponent{
+		{Component: "Mystery", Count: 2, Examples: []int{2}},
+		{Component: "Node", Subcomponent: "Other", Count: 1, Examples: []int{4}},
+	}
+	if !reflect.DeepEqual(unowned, expectedUnowned) {
+		t.Errorf("expected unowned %v, got %v", expectedUnowned, unowned)
+	}
+
+	unused, err := bd.UnusedComponents(time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedUnused := []UnusedComponent{
+		{Team: "Node", Component: "Node", Subcomponent: "CRI-O"},
+		{Team: "Storage", Component: "Storage"},
+	}
+	if !reflect.DeepEqual(unused, expectedUnused) {
+		t.Errorf("expected unused %v, got %v", expectedUnused, unused)
+	}
+}
@@ -0,0 +1,442 @@
+package bugs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+const (
+	filterFlagName   = "filter"
+	filterFlagDefVal = ""
+	filterFlagUsage  = `Only use bugs matching this expression, eg: severity in (high,urgent) and flag(blocker)=="?" and team=="Networking" and not keyword(UpcomingSprint)`
+)
+
+// Filter is a parsed filter expression. The language is:
+//
+//	expr       := expr "or" expr | expr "and" expr | "not" expr | "(" expr ")" | comparison | boolean
+//	comparison := value ("==" | "!=" | "=~" | "!~") literal | value "in" "(" literal ("," literal)* ")"
+//	value      := field | "flag(" name ")"
+//	boolean    := "blocker" | "untriaged" | "reviewed_in_sprint" | "keyword(" name ")" | "flag(" name ")"
+//
+// "and" binds tighter than "or". A literal is a bare word or a double quoted
+// string. Fields with several values, like component, match if any of their
+// values matches, and "!=" and "!~" match if none does. "=~" and "!~" take a
+// regular expression. flag(name) is the status of the flag, eg "+" or "?", and
+// on its own is true if the bug has the flag at all.
+type Filter struct {
+	source string
+	root   filterNode
+}
+
+// String returns the expression the filter was parsed from.
+func (f *Filter) String() string {
+	return f.source
+}
+
+// Match returns true if the bug matches the filter. orgData is only used if
+// the expression looks at the team.
+func (f *Filter) Match(bug *Bug, orgData *teams.OrgData) bool {
+	env := &filterEnv{bug: bug}
+	if orgData != nil {
+		current := orgData.Current()
+		env.orgData = &current
+	}
+	return f.root.eval(env)
+}
+
+type filterEnv struct {
+	bug     *Bug
+	orgData *teams.OrgData
+}
+
+type filterNode interface {
+	eval(env *filterEnv) bool
+}
+
+type andNode struct{ left, right filterNode }
+
+func (n andNode) eval(env *filterEnv) bool { return n.left.eval(env) && n.right.eval(env) }
+
+type orNode struct{ left, right filterNode }
+
+func (n orNode) eval(env *filterEnv) bool { return n.left.eval(env) || n.right.eval(env) }
+
+type notNode struct{ node filterNode }
+
+func (n notNode) eval(env *filterEnv) bool { return !n.node.eval(env) }
+
+type boolNode func(env *filterEnv) bool
+
+func (n boolNode) eval(env *filterEnv) bool { return n(env) }
+
+type valueFunc func(env *filterEnv) []string
+
+type compareNode struct {
+	value  valueFunc
+	negate bool
+	// match is true if a single value matches
+	match func(string) bool
+}
+
+func (n compareNode) eval(env *filterEnv) bool {
+	for _, v := range n.value(env) {
+		if n.match(v) {
+			return !n.negate
+		}
+	}
+	return n.negate
+}
+
+var (
+	filterFields = map[string]valueFunc{
+		"id":             func(env *filterEnv) []string { return []string{strconv.Itoa(env.bug.ID)} },
+		"summary":        func(env *filterEnv) []string { return []string{env.bug.Summary} },
+		"status":         func(env *filterEnv) []string { return []string{env.bug.Status} },
+		"resolution":     func(env *filterEnv) []string { return []string{env.bug.Resolution} },
+		"severity":       func(env *filterEnv) []string { return []string{env.bug.Severity} },
+		"priority":       func(env *filterEnv) []string { return []string{env.bug.Priority} },
+		"assigned_to":    func(env *filterEnv) []string { return []string{env.bug.AssignedTo} },
+		"product":        func(env *filterEnv) []string { return []string{env.bug.Product} },
+		"whiteboard":     func(env *filterEnv) []string { return []string{env.bug.Whiteboard} },
+		"target_release": func(env *filterEnv) []string { return env.bug.TargetRelease },
+		"version":        func(env *filterEnv) []string { return env.bug.Version },
+		"component":      func(env *filterEnv) []string { return env.bug.Component },
+		"keywords":       func(env *filterEnv) []string { return env.bug.Keywords },
+		"team": func(env *filterEnv) []string {
+			if env.orgData == nil {
+				return nil
+			}
+			return []string{env.orgData.GetTeamName(env.bug.APIBug())}
+		},
+	}
+
+	filterBooleans = map[string]boolNode{
+		"blocker":            func(env *filterEnv) bool { return env.bug.Blocker() },
+		"untriaged":          func(env *filterEnv) bool { return env.bug.Untriaged() },
+		"reviewed_in_sprint": func(env *filterEnv) bool { return env.bug.ReviewedInSprint() },
+	}
+)
+
+// ParseFilter parses a filter expression, see Filter for the language.
+func ParseFilter(source string) (*Filter, error) {
+	tokens, err := lexFilter(source)
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %v", source, err)
+	}
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokEOF {
+		err = p.errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %v", source, err)
+	}
+	return &Filter{source: source, root: root}, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type filterToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("_.-@/+:", c) >= 0
+}
+
+func lexFilter(source string) ([]filterToken, error) {
+	tokens := []filterToken{}
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{tokRParen, ")", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, filterToken{tokComma, ",", i})
+			i++
+		case c == '=' || c == '!':
+			op := source[i:]
+			if len(op) > 2 {
+				op = op[:2]
+			}
+			if op != "==" && op != "!=" && op != "=~" && op != "!~" {
+				return nil, fmt.Errorf("unknown operator at position %d", i)
+			}
+			tokens = append(tokens, filterToken{tokOp, op, i})
+			i += 2
+		case c == '"':
+			start := i
+			value := strings.Builder{}
+			for i++; i < len(source) && source[i] != '"'; i++ {
+				if source[i] == '\\' && i+1 < len(source) {
+					i++
+				}
+				value.WriteByte(source[i])
+			}
+			if i >= len(source) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			tokens = append(tokens, filterToken{tokString, value.String(), start})
+		case isWordChar(c):
+			start := i
+			for i < len(source) && isWordChar(source[i]) {
+				i++
+			}
+			tokens = append(tokens, filterToken{tokWord, source[start:i], start})
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", c, i)
+		}
+	}
+	return append(tokens, filterToken{tokEOF, "end of filter", len(source)}), nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// isKeyword returns true if the next token is the bare word keyword
+func (p *filterParser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokWord && strings.EqualFold(t.text, keyword)
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), p.peek().pos)
+}
+
+func (p *filterParser) expect(kind tokenKind, what string) (filterToken, error) {
+	if p.peek().kind != kind {
+		return filterToken{}, p.errorf("expected %s, got %q", what, p.peek().text)
+	}
+	return p.next(), nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.isKeyword("not") {
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseLiteral() (string, error) {
+	t := p.peek()
+	if t.kind != tokWord && t.kind != tokString {
+		return "", p.errorf("expected a value, got %q", t.text)
+	}
+	p.next()
+	return t.text, nil
+}
+
+// parseComparison parses a comparison, or a boolean on its own
+func (p *filterParser) parseComparison() (filterNode, error) {
+	nameToken, err := p.expect(tokWord, "a field")
+	if err != nil {
+		return nil, err
+	}
+	name := strings.ToLower(nameToken.text)
+
+	var value valueFunc
+	var boolean boolNode
+	if p.peek().kind == tokLParen {
+		p.next()
+		arg, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, `")"`); err != nil {
+			return nil, err
+		}
+		switch name {
+		case "flag":
+			value = func(env *filterEnv) []string {
+				out := []string{}
+				for _, flag := range env.bug.Flags {
+					if flag.Name == arg {
+						out = append(out, flag.Status)
+					}
+				}
+				return out
+			}
+			boolean = func(env *filterEnv) bool { return env.bug.Flag(arg, "") }
+		case "keyword":
+			boolean = func(env *filterEnv) bool {
+				for _, keyword := range env.bug.Keywords {
+					if keyword == arg {
+						return true
+					}
+				}
+				return false
+			}
+		default:
+			return nil, fmt.Errorf("unknown function %q at position %d", nameToken.text, nameToken.pos)
+		}
+	} else if f, ok := filterFields[name]; ok {
+		value = f
+	} else if b, ok := filterBooleans[name]; ok {
+		boolean = b
+	} else {
+		return nil, fmt.Errorf("unknown field %q at position %d", nameToken.text, nameToken.pos)
+	}
+
+	op := p.peek()
+	isCompare := op.kind == tokOp || p.isKeyword("in")
+	if !isCompare {
+		if boolean == nil {
+			return nil, p.errorf("%s needs to be compared to something", nameToken.text)
+		}
+		return boolean, nil
+	}
+	if value == nil {
+		return nil, p.errorf("%s can not be compared", nameToken.text)
+	}
+	p.next()
+
+	node := compareNode{value: value}
+	switch op.text {
+	case "==", "!=":
+		literal, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		node.match = func(v string) bool { return v == literal }
+		node.negate = op.text == "!="
+	case "=~", "!~":
+		literal, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(literal)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression at position %d: %v", op.pos, err)
+		}
+		node.match = re.MatchString
+		node.negate = op.text == "!~"
+	default:
+		// in
+		if _, err := p.expect(tokLParen, `"(" after in`); err != nil {
+			return nil, err
+		}
+		list := map[string]bool{}
+		for {
+			literal, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			list[literal] = true
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+		if _, err := p.expect(tokRParen, `")"`); err != nil {
+			return nil, err
+		}
+		node.match = func(v string) bool { return list[v] }
+	}
+	return node, nil
+}
+
+// Filter returns the bugs which match the filter.
+func (orig *BugData) Filter(f *Filter) *BugData {
+	return orig.filter(func(bug *Bug) bool {
+		return f.Match(bug, orig.orgData)
+	})
+}
+
+// GetFilter parses the --filter flag, it returns nil if the flag is not set.
+func GetFilter(cmd *cobra.Command) (*Filter, error) {
+	source, err := cmd.Flags().GetString(filterFlagName)
+	if err != nil {
+		return nil, err
+	}
+	if source == "" {
+		return nil, nil
+	}
+	return ParseFilter(source)
+}
@@ -0,0 +1,67 @@
+package bugs
+
+import (
+	"testing"
+
+	"github.com/eparis/bugzilla"
+
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+func TestFilter(t *testing.T) {
+	orgData := &teams.OrgData{
+		Teams: map[string]teams.TeamInfo{
+			"Networking": {Name: "Networking", Components: []string{"Networking"}},
+		},
+	}
+	bug := &Bug{
+		ID:            42,
+		Status:        "NEW",
+		Severity:      "high",
+		Component:     []string{"Networking"},
+		TargetRelease: []string{"4.7.0"},
+		Keywords:      []string{"Regression"},
+		Flags:         []bugzilla.Flag{{Name: "blocker", Status: "?"}},
+	}
+
+	tests := []struct {
+		filter   string
+		expected bool
+	}{
+		{`severity in (high,urgent) and flag(blocker)=="?" and team=="Networking" and not keyword(UpcomingSprint)`, true},
+		{`severity == low or status == NEW`, true},
+		{`severity == low or status == NEW and id == 1`, false},
+		{`(severity == low or status == NEW) and id == 42`, true},
+		{`target_release =~ "^4\\.7\\."`, true},
+		{`component != Networking`, false},
+		{`flag(blocker)`, true},
+		{`flag(blocker) == "+" or blocker`, false},
+		{`untriaged and not reviewed_in_sprint`, true},
+		{`NOT keyword(Regression)`, false},
+	}
+	for _, test := range tests {
+		f, err := ParseFilter(test.filter)
+		if err != nil {
+			t.Errorf("%s: %v", test.filter, err)
+			continue
+		}
+		if got := f.Match(bug, orgData); got != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.filter, test.expected, got)
+		}
+	}
+
+	for _, bad := range []string{
+		`severity`,
+		`severity = high`,
+		`nope == 1`,
+		`keyword(Regression) == 1`,
+		`severity in high`,
+		`(severity == high`,
+		`summary == "unterminated`,
+		`status == NEW status == NEW`,
+	} {
+		if _, err := ParseFilter(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package bugs
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	reconcileDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bugdata_reconcile_duration_seconds",
+		Help:    "How long loading bugs from bugzilla took",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 10),
+	}, []string{"type"})
+	reconcileErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bugdata_reconcile_errors_total",
+		Help: "Failed attempts to load bugs from bugzilla",
+	}, []string{"type"})
+	reconcileLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bugdata_last_reconcile_success_timestamp_seconds",
+		Help: "When bugs were last loaded from bugzilla",
+	})
+	loadedBugs = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bugdata_bugs",
+		Help: "Bugs currently loaded",
+	})
+)
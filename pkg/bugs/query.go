@@ -0,0 +1,165 @@
+package bugs
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/eparis/bugzilla"
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+)
+
+const (
+	queryConfigFlagName   = "bug-query-config"
+	queryConfigFlagDefVal = ""
+	queryConfigFlagUsage  = "Path to a YAML file describing which bugs to load, flags below override it"
+
+	classificationFlagName  = "bug-classification"
+	classificationFlagUsage = "Classifications of the bugs to load"
+
+	productFlagName  = "bug-product"
+	productFlagUsage = "Products of the bugs to load"
+
+	statusFlagName  = "bug-status"
+	statusFlagUsage = "Statuses of the bugs to load"
+
+	excludeComponentsFlagName  = "bug-exclude-components"
+	excludeComponentsFlagUsage = "Components whose bugs are never loaded"
+
+	includeFieldsFlagName  = "bug-include-fields"
+	includeFieldsFlagUsage = "Fields loaded for every bug, id, component, sub_components and last_change_time are always loaded"
+)
+
+var (
+	// fields BugData can not work without, they are used to find the team
+	// of a bug and to only load the bugs changed since the last reconcile
+	requiredFields = []string{"id", "component", "sub_components", "last_change_time"}
+)
+
+// QueryConfig describes which bugs, and which of their fields, BugData loads
+// from bugzilla.
+type QueryConfig struct {
+	Classification    []string `json:"classification,omitempty"`
+	Product           []string `json:"product,omitempty"`
+	Status            []string `json:"status,omitempty"`
+	ExcludeComponents []string `json:"excludeComponents,omitempty"`
+	IncludeFields     []string `json:"includeFields,omitempty"`
+}
+
+// DefaultQueryConfig is every open OCP bug which is not documentation.
+func DefaultQueryConfig() QueryConfig {
+	return QueryConfig{
+		Classification:    []string{"Red Hat"},
+		Product:           []string{"OpenShift Container Platform"},
+		Status:            []string{"NEW", "ASSIGNED", "POST", "ON_DEV", "MODIFIED"},
+		ExcludeComponents: []string{"Documentation"},
+		IncludeFields:     []string{"id", "summary", "status", "severity", "priority", "assigned_to", "target_release", "component", "sub_components", "keywords", "cf_pm_score", "flags", "creation_time", "whiteboard"},
+	}
+}
+
+// Query returns the bugzilla search for the config.
+func (c QueryConfig) Query() bugzilla.Query {
+	query := bugzilla.Query{
+		Classification: c.Classification,
+		Product:        c.Product,
+		Status:         c.Status,
+	}
+	if len(c.IncludeFields) > 0 {
+		query.IncludeFields = append([]string{}, c.IncludeFields...)
+		for _, field := range requiredFields {
+			if !contains(query.IncludeFields, field) {
+				query.IncludeFields = append(query.IncludeFields, field)
+			}
+		}
+	}
+	for _, component := range c.ExcludeComponents {
+		query.Advanced = append(query.Advanced, bugzilla.AdvancedQuery{
+			Field:  "component",
+			Op:     "equals",
+			Value:  component,
+			Negate: true,
+		})
+	}
+	return query
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// GetQueryConfig starts from the defaults, replaces every field set in the
+// --bug-query-config file and then every field set on the command line.
+func GetQueryConfig(cmd *cobra.Command) (QueryConfig, error) {
+	config := DefaultQueryConfig()
+
+	path, err := cmd.Flags().GetString(queryConfigFlagName)
+	if err != nil {
+		return config, err
+	}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return config, err
+		}
+		fromFile := QueryConfig{}
+		if err := yaml.Unmarshal(data, &fromFile); err != nil {
+			return config, fmt.Errorf("unable to parse %s: %v", path, err)
+		}
+		if fromFile.Classification != nil {
+			config.Classification = fromFile.Classification
+		}
+		if fromFile.Product != nil {
+			config.Product = fromFile.Product
+		}
+		if fromFile.Status != nil {
+			config.Status = fromFile.Status
+		}
+		if fromFile.ExcludeComponents != nil {
+			config.ExcludeComponents = fromFile.ExcludeComponents
+		}
+		if fromFile.IncludeFields != nil {
+			config.IncludeFields = fromFile.IncludeFields
+		}
+	}
+
+	flags := []struct {
+		name  string
+		field *[]string
+	}{
+		{classificationFlagName, &config.Classification},
+		{productFlagName, &config.Product},
+		{statusFlagName, &config.Status},
+		{excludeComponentsFlagName, &config.ExcludeComponents},
+		{includeFieldsFlagName, &config.IncludeFields},
+	}
+	for _, flag := range flags {
+		if !cmd.Flags().Changed(flag.name) {
+			continue
+		}
+		value, err := cmd.Flags().GetStringSlice(flag.name)
+		if err != nil {
+			return config, err
+		}
+		*flag.field = value
+	}
+
+	if len(config.Product) == 0 {
+		return config, fmt.Errorf("at least one product must be queried")
+	}
+	return config, nil
+}
+
+func addQueryFlags(cmd *cobra.Command) {
+	def := DefaultQueryConfig()
+	cmd.Flags().String(queryConfigFlagName, queryConfigFlagDefVal, queryConfigFlagUsage)
+	cmd.Flags().StringSlice(classificationFlagName, def.Classification, classificationFlagUsage)
+	cmd.Flags().StringSlice(productFlagName, def.Product, productFlagUsage)
+	cmd.Flags().StringSlice(statusFlagName, def.Status, statusFlagUsage)
+	cmd.Flags().StringSlice(excludeComponentsFlagName, def.ExcludeComponents, excludeComponentsFlagUsage)
+	cmd.Flags().StringSlice(includeFieldsFlagName, def.IncludeFields, includeFieldsFlagUsage)
+}
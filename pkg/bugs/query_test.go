@@ -0,0 +1,50 @@
+package bugs
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestGetQueryConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("product: [OKD]\nstatus: [NEW, ON_QA]\nexcludeComponents: []\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	cmd := &cobra.Command{}
+	AddFlags(cmd)
+	if err := cmd.Flags().Parse([]string{"--bug-query-config=" + f.Name(), "--bug-status=VERIFIED", "--bug-include-fields=summary"}); err != nil {
+		t.Fatal(err)
+	}
+	config, err := GetQueryConfig(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := QueryConfig{
+		Classification:    []string{"Red Hat"},
+		Product:           []string{"OKD"},
+		Status:            []string{"VERIFIED"},
+		ExcludeComponents: []string{},
+		IncludeFields:     []string{"summary"},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %#v, got %#v", expected, config)
+	}
+
+	query := config.Query()
+	if len(query.Advanced) != 0 {
+		t.Errorf("expected no excluded components, got %#v", query.Advanced)
+	}
+	if fields := []string{"summary", "id", "component", "sub_components", "last_change_time"}; !reflect.DeepEqual(query.IncludeFields, fields) {
+		t.Errorf("expected fields %v, got %v", fields, query.IncludeFields)
+	}
+}
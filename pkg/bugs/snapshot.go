@@ -0,0 +1,124 @@
+package bugs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eparis/bugzilla"
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"github.com/openshift/bugzilla-tools/pkg/cache"
+)
+
+const (
+	snapshotFlagName   = "bug-snapshot"
+	snapshotFlagDefVal = ""
+	snapshotFlagUsage  = "Path to a database every reconciled set of bugs is saved to. At startup the last one is served while bugzilla is queried in the background"
+
+	snapshotCacheName = "bug-data-snapshot"
+)
+
+// snapshot is everything needed to pick a BugData up where it was left
+type snapshot struct {
+	Time      time.Time      `json:"time"`
+	HighWater time.Time      `json:"highWater"`
+	LastFull  time.Time      `json:"lastFull"`
+	Query     bugzilla.Query `json:"query"`
+	Filter    string         `json:"filter,omitempty"`
+	Bugs      []*Bug         `json:"bugs"`
+}
+
+// SnapshotPath returns the --bug-snapshot database, "" if it is not set.
+func SnapshotPath(cmd *cobra.Command) (string, error) {
+	return cmd.Flags().GetString(snapshotFlagName)
+}
+
+// openSnapshots opens the snapshot database if --bug-snapshot is set and
+// returns true if snapshots should be used. There is only one cache, so it
+// fails if another database is already open.
+func openSnapshots(cmd *cobra.Command) (bool, error) {
+	path, err := SnapshotPath(cmd)
+	if err != nil {
+		return false, err
+	}
+	if path == "" {
+		return false, nil
+	}
+	if !cache.IsOpen() {
+		cache.Open(path)
+	} else if cache.Path() != path {
+		return false, fmt.Errorf("unable to use the bug snapshot %s, the cache %s is already open", path, cache.Path())
+	}
+	return true, nil
+}
+
+// saveSnapshot persists the current bugs. Failing to save only costs a slower
+// start next time, so it is logged and not returned.
+func (bd *BugData) saveSnapshot() {
+	if !bd.snapshots {
+		return
+	}
+	s := snapshot{
+		Time:      bd.Updated(),
+		HighWater: bd.highWater,
+		LastFull:  bd.lastFull,
+		Query:     bd.query,
+		Filter:    bd.filterSource(),
+		Bugs:      bd.GetBugs(),
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		klog.Warningf("Unable to encode bug snapshot: %v", err)
+		return
+	}
+	cache.Set(snapshotCacheName, s.Time.UTC().Format(time.RFC3339Nano), data)
+}
+
+func (bd *BugData) filterSource() string {
+	if bd.bugFilter == nil {
+		return ""
+	}
+	return bd.bugFilter.String()
+}
+
+// loadSnapshot fills the BugData from the last snapshot. It returns false if
+// there is none, or if it was taken with a different query.
+func (bd *BugData) loadSnapshot() (bool, error) {
+	data, _, err := cache.GetLatest(snapshotCacheName)
+	if err != nil {
+		return false, err
+	}
+	if len(data) == 0 {
+		return false, nil
+	}
+	s := snapshot{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return false, fmt.Errorf("unable to decode bug snapshot: %v", err)
+	}
+	// compare the encoded queries so empty and unset fields are the same
+	taken, _ := json.Marshal(s.Query)
+	current, _ := json.Marshal(bd.query)
+	if string(taken) != string(current) || s.Filter != bd.filterSource() {
+		klog.Infof("Ignoring the bug snapshot from %v, it was taken with a different query or filter", s.Time)
+		return false, nil
+	}
+	bd.update(s.Bugs, s.Time)
+	bd.highWater = s.HighWater
+	bd.lastFull = s.LastFull
+	klog.Infof("Loaded %d bugs from the snapshot taken %v ago", len(s.Bugs), bd.Age().Round(time.Second))
+	return true, nil
+}
+
+// SetAgeHeaders tells API clients how old the bugs they were served are, as
+// Last-Modified and as X-Snapshot-Age in seconds.
+func SetAgeHeaders(header http.Header, updated time.Time) {
+	if updated.IsZero() {
+		return
+	}
+	header.Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+	header.Set("X-Snapshot-Age", strconv.Itoa(int(time.Since(updated).Seconds())))
+}
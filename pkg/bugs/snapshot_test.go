@@ -0,0 +1,103 @@
+package bugs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/cache"
+	"github.com/openshift/bugzilla-tools/pkg/fakebugzilla"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+// TestMain serves --test-bug-data from a fake bugzilla
+func TestMain(m *testing.M) {
+	fakes := &fakebugzilla.TestClients{}
+	TestClient = fakes.Client
+	code := m.Run()
+	fakes.Close()
+	os.Exit(code)
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer cache.Close()
+
+	bug := func(id string) string {
+		return "- id: " + id + `
+  classification: Red Hat
+  product: OpenShift Container Platform
+  component: [Networking]
+  status: NEW
+  last_change_time: "2020-10-01T00:00:00Z"
+`
+	}
+	getCmd := func(fixture string, args ...string) *cobra.Command {
+		path := filepath.Join(dir, "bugs.yaml")
+		if err := ioutil.WriteFile(path, []byte(fixture), 0600); err != nil {
+			t.Fatal(err)
+		}
+		cmd := &cobra.Command{}
+		AddFlags(cmd)
+		args = append([]string{"--test-bug-data=" + path, "--bug-snapshot=" + filepath.Join(dir, "snapshot.db")}, args...)
+		if err := cmd.Flags().Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return cmd
+	}
+	getBugData := func(fixture string, args ...string) *BugData {
+		bd, err := GetBugData(getCmd(fixture, args...), &teams.OrgData{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bd
+	}
+
+	first := getBugData(bug("1") + bug("2"))
+	if first.Length() != 2 {
+		t.Fatalf("expected 2 bugs, got %d", first.Length())
+	}
+
+	// bug 2 was closed while the service was down
+	second := getBugData(bug("1"))
+	if second.Length() != 2 || !second.Updated().Equal(first.Updated()) {
+		t.Errorf("expected the snapshot to be served, got %d bugs from %v", second.Length(), second.Updated())
+	}
+	if !second.Loaded() || second.Reconciled() {
+		t.Errorf("expected the snapshot to be loaded but not reconciled")
+	}
+	second.fullResync = 0
+	if err := second.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	if second.Length() != 1 || !second.Updated().After(first.Updated()) {
+		t.Errorf("expected the reconcile to replace the snapshot, got %d bugs from %v", second.Length(), second.Updated())
+	}
+
+	// one-shot commands never use the snapshot as it is
+	third, err := GetReconciledBugData(getCmd(bug("1")), &teams.OrgData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !third.Reconciled() || !third.Updated().After(second.Updated()) {
+		t.Errorf("expected the snapshot to be brought up to date, got bugs from %v", third.Updated())
+	}
+
+	// a different query can not use the snapshot
+	fourth := getBugData(bug("1"), "--bug-status=ON_QA")
+	if fourth.Length() != 0 {
+		t.Errorf("expected the snapshot to be ignored, got %d bugs", fourth.Length())
+	}
+
+	// only one snapshot database can be open
+	if _, err := GetBugData(getCmd(bug("1"), "--bug-snapshot="+filepath.Join(dir, "other.db")), &teams.OrgData{}); err == nil {
+		t.Errorf("expected an error using a second snapshot database")
+	}
+}
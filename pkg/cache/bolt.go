@@ -7,7 +7,11 @@ import (
 	"k8s.io/klog"
 )
 
-var db *bolt.DB = nil
+var (
+	db *bolt.DB = nil
+	// dbPath is the path db was opened at
+	dbPath string
+)
 
 func Open(path string) {
 	var err error
@@ -15,11 +19,24 @@ func Open(path string) {
 	if err != nil {
 		klog.Fatalf("Failed opening cache at %s: %v", path, err)
 	}
+	dbPath = path
+}
+
+// IsOpen returns true once Open succeeded
+func IsOpen() bool {
+	return db != nil
+}
+
+// Path returns the path the cache was opened at, "" if it is not open
+func Path() string {
+	return dbPath
 }
 
 func Close() {
 	if db != nil {
 		db.Close()
+		db = nil
+		dbPath = ""
 	}
 }
 
@@ -70,3 +87,26 @@ func Set(name string, revision string, data []byte) {
 		return err
 	})
 }
+
+// GetLatest returns whatever is cached for name along with its revision, no
+// matter which revision that is.
+func GetLatest(name string) ([]byte, string, error) {
+	if db == nil {
+		return nil, "", nil
+	}
+
+	var res []byte
+	var revision string
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(name))
+		if b == nil {
+			return nil
+		}
+		revision = string(b.Get([]byte("revision")))
+		v := b.Get([]byte("data"))
+		res = make([]byte, len(v))
+		copy(res, v)
+		return nil
+	})
+	return res, revision, err
+}
@@ -0,0 +1,413 @@
+package fakebugzilla
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eparis/bugzilla"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// matcher returns true if the bug, with its history, is part of the search result
+type matcher func(bug *bugzilla.Bug, history []bugzilla.History) (bool, error)
+
+var (
+	// search parameters which are not used to filter bugs
+	ignoredParams = sets.NewString("limit", "offset", "include_fields", "api_key", "query_format")
+
+	// search parameters which match any of their values exactly against a field
+	exactParams = map[string]string{
+		"classification": "classification",
+		"product":        "product",
+		"bug_status":     "status",
+		"status":         "status",
+		"priority":       "priority",
+		"bug_severity":   "severity",
+		"severity":       "severity",
+		"component":      "component",
+		"target_release": "target_release",
+		"assigned_to":    "assigned_to",
+		"version":        "version",
+		"resolution":     "resolution",
+	}
+
+	// names the advanced search uses for fields whose REST name differs
+	fieldAliases = map[string]string{
+		"bug_id":            "id",
+		"bug_status":        "status",
+		"bug_severity":      "severity",
+		"dependson":         "depends_on",
+		"blocked":           "blocks",
+		"short_desc":        "summary",
+		"status_whiteboard": "whiteboard",
+		"rep_platform":      "platform",
+		"delta_ts":          "last_change_time",
+		"creation_ts":       "creation_time",
+		"reporter":          "creator",
+		"sub_components":    "sub_components",
+	}
+
+	advancedFieldRegexp = regexp.MustCompile(`^f([0-9]+)$`)
+
+	knownFields = bugFields()
+)
+
+// bugFields returns the JSON name of every field of a bug.
+func bugFields() sets.String {
+	fields := sets.NewString("flagtypes.name")
+	t := reflect.TypeOf(bugzilla.Bug{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields.Insert(name)
+		}
+	}
+	return fields
+}
+
+func fieldName(field string) (string, error) {
+	if alias, ok := fieldAliases[field]; ok {
+		field = alias
+	}
+	if !knownFields.Has(field) {
+		return "", fmt.Errorf("unknown field %q", field)
+	}
+	return field, nil
+}
+
+// fieldValues returns every value of a field as strings. Flags are returned
+// as their name followed by their status, eg "blocker+".
+func fieldValues(bug *bugzilla.Bug, field string) []string {
+	if field == "flagtypes.name" {
+		out := []string{}
+		for _, flag := range bug.Flags {
+			out = append(out, flag.Name+flag.Status)
+		}
+		return out
+	}
+	data, _ := json.Marshal(bug)
+	all := map[string]interface{}{}
+	_ = json.Unmarshal(data, &all)
+	return stringValues(all[field])
+}
+
+func stringValues(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case []interface{}:
+		out := []string{}
+		for _, item := range v {
+			out = append(out, stringValues(item)...)
+		}
+		return out
+	case map[string]interface{}:
+		out := []string{}
+		for _, item := range v {
+			out = append(out, stringValues(item)...)
+		}
+		return out
+	}
+	return []string{fmt.Sprintf("%v", value)}
+}
+
+func anyValue(values []string, f func(string) bool) bool {
+	for _, v := range values {
+		if f(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func words(in string) []string {
+	return strings.FieldsFunc(in, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+}
+
+func compare(a, b string) int {
+	af, aErr := strconv.ParseFloat(a, 64)
+	bf, bErr := strconv.ParseFloat(b, 64)
+	if aErr == nil && bErr == nil {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+	if at, err := parseTime(a, time.Now()); err == nil {
+		if bt, err := parseTime(b, time.Now()); err == nil {
+			switch {
+			case at.Before(bt):
+				return -1
+			case at.After(bt):
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// parseTime understands absolute times and bugzilla's relative times like
+// "-2d" or "-1w".
+func parseTime(value string, now time.Time) (time.Time, error) {
+	for _, format := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(format, value); err == nil {
+			return t, nil
+		}
+	}
+	if len(value) > 2 && value[0] == '-' {
+		n, err := strconv.Atoi(value[1 : len(value)-1])
+		if err == nil {
+			switch value[len(value)-1] {
+			case 'h':
+				return now.Add(-time.Duration(n) * time.Hour), nil
+			case 'd':
+				return now.AddDate(0, 0, -n), nil
+			case 'w':
+				return now.AddDate(0, 0, -7*n), nil
+			case 'm':
+				return now.AddDate(0, -n, 0), nil
+			case 'y':
+				return now.AddDate(-n, 0, 0), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time %q", value)
+}
+
+// valueMatch matches the values of a field against an operator and value from
+// https://bugzilla.readthedocs.io/en/latest/api/core/v1/bug.html#search-bugs
+func valueMatch(values []string, op, value string) (bool, error) {
+	lower := strings.ToLower(value)
+	switch op {
+	case "equals":
+		return anyValue(values, func(v string) bool { return v == value }), nil
+	case "notequals":
+		return !anyValue(values, func(v string) bool { return v == value }), nil
+	case "anyexact":
+		exact := sets.NewString()
+		for _, w := range strings.Split(value, ",") {
+			exact.Insert(strings.TrimSpace(w))
+		}
+		return anyValue(values, exact.Has), nil
+	case "substring":
+		return anyValue(values, func(v string) bool { return strings.Contains(strings.ToLower(v), lower) }), nil
+	case "casesubstring":
+		return anyValue(values, func(v string) bool { return strings.Contains(v, value) }), nil
+	case "notsubstring":
+		return !anyValue(values, func(v string) bool { return strings.Contains(strings.ToLower(v), lower) }), nil
+	case "regexp", "notregexp":
+		re, err := regexp.Compile("(?i)" + value)
+		if err != nil {
+			return false, err
+		}
+		found := anyValue(values, re.MatchString)
+		return found == (op == "regexp"), nil
+	case "lessthan":
+		return anyValue(values, func(v string) bool { return compare(v, value) < 0 }), nil
+	case "lessthaneq":
+		return anyValue(values, func(v string) bool { return compare(v, value) <= 0 }), nil
+	case "greaterthan":
+		return anyValue(values, func(v string) bool { return compare(v, value) > 0 }), nil
+	case "greaterthaneq":
+		return anyValue(values, func(v string) bool { return compare(v, value) >= 0 }), nil
+	case "isempty":
+		return !anyValue(values, func(v string) bool { return v != "" }), nil
+	case "isnotempty":
+		return anyValue(values, func(v string) bool { return v != "" }), nil
+	case "anywords", "allwords", "nowords", "anywordssubstr", "allwordssubstr", "nowordssubstr":
+		have := []string{}
+		for _, v := range values {
+			have = append(have, words(strings.ToLower(v))...)
+		}
+		substr := strings.HasSuffix(op, "substr")
+		found := 0
+		wanted := words(lower)
+		for _, w := range wanted {
+			if anyValue(have, func(h string) bool { return h == w || (substr && strings.Contains(h, w)) }) {
+				found++
+			}
+		}
+		switch strings.TrimSuffix(op, "substr") {
+		case "anywords":
+			return found > 0, nil
+		case "allwords":
+			return found == len(wanted), nil
+		}
+		return found == 0, nil
+	}
+	return false, fmt.Errorf("unsupported operator %q", op)
+}
+
+// historyMatch handles the operators which look at how a field changed.
+func historyMatch(history []bugzilla.History, field, op, value string) (bool, error) {
+	var when time.Time
+	if op == "changedbefore" || op == "changedafter" {
+		var err error
+		when, err = parseTime(value, time.Now())
+		if err != nil {
+			return false, err
+		}
+	}
+	for _, h := range history {
+		changed, err := parseTime(h.When, time.Now())
+		if err != nil {
+			return false, err
+		}
+		for _, change := range h.Changes {
+			if change.FieldName != field {
+				continue
+			}
+			switch op {
+			case "changedbefore":
+				if changed.Before(when) {
+					return true, nil
+				}
+			case "changedafter":
+				if changed.After(when) {
+					return true, nil
+				}
+			case "changedfrom":
+				if change.Removed == value {
+					return true, nil
+				}
+			case "changedto":
+				if change.Added == value {
+					return true, nil
+				}
+			case "changedby":
+				if h.Who == value {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+func isHistoryOp(op string) bool {
+	return strings.HasPrefix(op, "changed")
+}
+
+// parseSearch turns the parameters of a search request into a matcher. Every
+// condition must match. Unknown parameters, fields and operators are errors so
+// a test can not silently pass with a query real bugzilla would reject.
+func parseSearch(values url.Values) (matcher, error) {
+	conditions := []matcher{}
+	add := func(m matcher) {
+		conditions = append(conditions, m)
+	}
+
+	for param, vals := range values {
+		param, vals := param, vals
+		switch {
+		case ignoredParams.Has(param):
+		case exactParams[param] != "":
+			field := exactParams[param]
+			add(func(bug *bugzilla.Bug, _ []bugzilla.History) (bool, error) {
+				exact := sets.NewString(vals...)
+				return anyValue(fieldValues(bug, field), exact.Has), nil
+			})
+		case param == "keywords":
+			op := values.Get("keywords_type")
+			if op == "" {
+				op = "allwords"
+			}
+			add(func(bug *bugzilla.Bug, _ []bugzilla.History) (bool, error) {
+				return valueMatch(bug.Keywords, op, strings.Join(vals, " "))
+			})
+		case param == "keywords_type", param == "bug_id_type":
+		case param == "bug_id", param == "id":
+			op := values.Get("bug_id_type")
+			if op == "" {
+				op = "anyexact"
+			}
+			ids := strings.Join(vals, ",")
+			add(func(bug *bugzilla.Bug, _ []bugzilla.History) (bool, error) {
+				found, err := valueMatch([]string{strconv.Itoa(bug.ID)}, "anyexact", ids)
+				if op == "nowords" {
+					return !found, err
+				}
+				return found, err
+			})
+		case param == "last_change_time", param == "creation_time":
+			// bugs changed or created at or after the given time
+			since, err := parseTime(values.Get(param), time.Now())
+			if err != nil {
+				return nil, err
+			}
+			add(func(bug *bugzilla.Bug, _ []bugzilla.History) (bool, error) {
+				for _, v := range fieldValues(bug, param) {
+					t, err := parseTime(v, time.Now())
+					if err != nil {
+						return false, err
+					}
+					if !t.Before(since) {
+						return true, nil
+					}
+				}
+				return false, nil
+			})
+		case advancedFieldRegexp.MatchString(param):
+			n := advancedFieldRegexp.FindStringSubmatch(param)[1]
+			field, err := fieldName(values.Get(param))
+			if err != nil {
+				return nil, err
+			}
+			op := values.Get("o" + n)
+			value := values.Get("v" + n)
+			negate := values.Get("n"+n) == "1"
+			if !isHistoryOp(op) {
+				if _, err := valueMatch(nil, op, value); err != nil {
+					return nil, err
+				}
+			}
+			add(func(bug *bugzilla.Bug, history []bugzilla.History) (bool, error) {
+				var found bool
+				var err error
+				if isHistoryOp(op) {
+					found, err = historyMatch(history, field, op, value)
+				} else {
+					found, err = valueMatch(fieldValues(bug, field), op, value)
+				}
+				return found != negate, err
+			})
+		case len(param) > 1 && strings.ContainsAny(param[:1], "ovn") && isNumber(param[1:]):
+			// the operator, value and negation of an advanced field
+		default:
+			return nil, fmt.Errorf("unsupported search parameter %q", param)
+		}
+	}
+
+	return func(bug *bugzilla.Bug, history []bugzilla.History) (bool, error) {
+		for _, condition := range conditions {
+			matched, err := condition(bug, history)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	}, nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
@@ -0,0 +1,453 @@
+// Package fakebugzilla is an in-process stand in for the Bugzilla REST API.
+//
+// It serves the subset of https://bugzilla.readthedocs.io/en/latest/api/ used
+// by github.com/eparis/bugzilla: searching, including advanced queries, getting
+// and updating bugs, comments and history. Its state is loaded from a fixture
+// and changed by updates, so commands can be run end to end without the
+// network and the result checked afterwards.
+package fakebugzilla
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eparis/bugzilla"
+	"github.com/ghodss/yaml"
+)
+
+const (
+	// DefaultUser is recorded as the author of every update and comment
+	DefaultUser = "fake-bugzilla@example.com"
+	// DefaultPageSize is the most bugs returned by a single search request,
+	// small enough that clients have to page through results
+	DefaultPageSize = 20
+
+	timeFormat = time.RFC3339
+)
+
+// Fixture is the state of a fake bugzilla. Comments and history are keyed by
+// bug ID.
+type Fixture struct {
+	Bugs     []bugzilla.Bug             `json:"bugs"`
+	Comments map[int][]bugzilla.Comment `json:"comments,omitempty"`
+	History  map[int][]bugzilla.History `json:"history,omitempty"`
+}
+
+// LoadFixture reads a fixture from YAML or JSON. For compatibility with old
+// test data a file holding only a list of bugs is accepted as well.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	j, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	fixture := &Fixture{}
+	if strings.HasPrefix(strings.TrimSpace(string(j)), "[") {
+		err = json.Unmarshal(j, &fixture.Bugs)
+	} else {
+		err = json.Unmarshal(j, fixture)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return fixture, nil
+}
+
+// Server is a running fake bugzilla.
+type Server struct {
+	*httptest.Server
+
+	sync.Mutex
+	bugs     map[int]*bugzilla.Bug
+	comments map[int][]bugzilla.Comment
+	history  map[int][]bugzilla.History
+	pageSize int
+	// now is used to timestamp updates, it can be replaced in tests
+	now func() time.Time
+}
+
+// New starts a fake bugzilla serving the fixture. Close must be called when it
+// is no longer needed.
+func New(fixture *Fixture) *Server {
+	s := &Server{
+		bugs:     map[int]*bugzilla.Bug{},
+		comments: map[int][]bugzilla.Comment{},
+		history:  map[int][]bugzilla.History{},
+		pageSize: DefaultPageSize,
+		now:      time.Now,
+	}
+	for i := range fixture.Bugs {
+		bug := fixture.Bugs[i]
+		s.bugs[bug.ID] = &bug
+	}
+	for id, comments := range fixture.Comments {
+		s.comments[id] = append([]bugzilla.Comment{}, comments...)
+	}
+	for id, history := range fixture.History {
+		s.history[id] = append([]bugzilla.History{}, history...)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// NewFromFile starts a fake bugzilla serving the fixture at path.
+func NewFromFile(path string) (*Server, error) {
+	fixture, err := LoadFixture(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(fixture), nil
+}
+
+// SetPageSize changes the most bugs returned by a single search request.
+func (s *Server) SetPageSize(size int) {
+	s.Lock()
+	defer s.Unlock()
+	s.pageSize = size
+}
+
+// Client returns a bugzilla client talking to the fake.
+func (s *Server) Client() bugzilla.Client {
+	return bugzilla.NewClient(func() []byte { return []byte("fake-api-key") }, s.URL)
+}
+
+func copyBug(bug *bugzilla.Bug) *bugzilla.Bug {
+	data, _ := json.Marshal(bug)
+	out := &bugzilla.Bug{}
+	_ = json.Unmarshal(data, out)
+	return out
+}
+
+// Bug returns a copy of the current state of a bug, or nil if it does not exist.
+func (s *Server) Bug(id int) *bugzilla.Bug {
+	s.Lock()
+	defer s.Unlock()
+	bug, ok := s.bugs[id]
+	if !ok {
+		return nil
+	}
+	return copyBug(bug)
+}
+
+// Comments returns every comment on a bug, oldest first.
+func (s *Server) Comments(id int) []bugzilla.Comment {
+	s.Lock()
+	defer s.Unlock()
+	return append([]bugzilla.Comment{}, s.comments[id]...)
+}
+
+// History returns every change made to a bug, oldest first.
+func (s *Server) History(id int) []bugzilla.History {
+	s.Lock()
+	defer s.Unlock()
+	return append([]bugzilla.History{}, s.history[id]...)
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "rest" || parts[1] != "bug" {
+		http.Error(w, fmt.Sprintf("unknown path %s", r.URL.Path), http.StatusNotFound)
+		return
+	}
+	if len(parts) == 2 {
+		if r.Method != http.MethodGet {
+			http.Error(w, fmt.Sprintf("unsupported method %s", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleSearch(w, r)
+		return
+	}
+
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("malformed bug id %q", parts[2]), http.StatusBadRequest)
+		return
+	}
+	bug, ok := s.bugs[id]
+	if !ok {
+		http.Error(w, fmt.Sprintf("bug %d not found", id), http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 3 && r.Method == http.MethodGet:
+		s.handleGet(w, r, bug)
+	case len(parts) == 3 && r.Method == http.MethodPut:
+		s.handleUpdate(w, r, bug)
+	case len(parts) == 4 && parts[3] == "comment" && r.Method == http.MethodGet:
+		writeJSON(w, map[string]map[string]interface{}{
+			"bugs": {
+				strconv.Itoa(id): map[string][]bugzilla.Comment{"comments": s.comments[id]},
+			},
+		})
+	case len(parts) == 4 && parts[3] == "history" && r.Method == http.MethodGet:
+		writeJSON(w, map[string][]interface{}{
+			"bugs": {
+				map[string]interface{}{"id": id, "history": s.history[id]},
+			},
+		})
+	default:
+		http.Error(w, fmt.Sprintf("unsupported %s %s", r.Method, r.URL.Path), http.StatusBadRequest)
+	}
+}
+
+// project returns the bug with only the requested fields, as bugzilla does
+// when include_fields is set.
+func project(bug *bugzilla.Bug, include string) (interface{}, error) {
+	if include == "" || include == "_all" || include == "_default" {
+		return bug, nil
+	}
+	data, err := json.Marshal(bug)
+	if err != nil {
+		return nil, err
+	}
+	all := map[string]interface{}{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{}
+	for _, field := range strings.Split(include, ",") {
+		if value, ok := all[field]; ok {
+			out[field] = value
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request, bug *bugzilla.Bug) {
+	out, err := project(bug, r.URL.Query().Get("include_fields"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string][]interface{}{"bugs": {out}})
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	match, err := parseSearch(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ids := []int{}
+	for id, bug := range s.bugs {
+		matched, err := match(bug, s.history[id])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if matched {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	limit := s.pageSize
+	if l, err := strconv.Atoi(values.Get("limit")); err == nil && l > 0 && l < limit {
+		limit = l
+	}
+	offset, _ := strconv.Atoi(values.Get("offset"))
+	if offset > len(ids) {
+		offset = len(ids)
+	}
+	end := offset + limit
+	if end > len(ids) {
+		end = len(ids)
+	}
+
+	bugs := []interface{}{}
+	for _, id := range ids[offset:end] {
+		out, err := project(s.bugs[id], values.Get("include_fields"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		bugs = append(bugs, out)
+	}
+	writeJSON(w, map[string][]interface{}{"bugs": bugs})
+}
+
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request, bug *bugzilla.Bug) {
+	update := bugzilla.BugUpdate{}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, fmt.Sprintf("unable to decode update: %v", err), http.StatusBadRequest)
+		return
+	}
+	now := s.now().UTC().Format(timeFormat)
+	changes := applyUpdate(bug, update)
+	if len(changes) > 0 {
+		s.history[bug.ID] = append(s.history[bug.ID], bugzilla.History{
+			When:    now,
+			Who:     DefaultUser,
+			Changes: changes,
+		})
+	}
+	if update.Comment != nil {
+		comments := s.comments[bug.ID]
+		comments = append(comments, bugzilla.Comment{
+			Id:           s.nextCommentID(),
+			BugId:        bug.ID,
+			Count:        len(comments),
+			Text:         update.Comment.Body,
+			Creator:      DefaultUser,
+			Time:         now,
+			CreationTime: now,
+			IsPrivate:    update.Comment.Private,
+			IsMarkdown:   update.Comment.Markdown,
+		})
+		s.comments[bug.ID] = comments
+	}
+	if len(changes) > 0 || update.Comment != nil {
+		bug.LastChangeTime = now
+	}
+	writeJSON(w, map[string][]interface{}{
+		"bugs": {map[string]interface{}{"id": bug.ID, "last_change_time": bug.LastChangeTime}},
+	})
+}
+
+func (s *Server) nextCommentID() int {
+	id := 0
+	for _, comments := range s.comments {
+		for _, comment := range comments {
+			if comment.Id > id {
+				id = comment.Id
+			}
+		}
+	}
+	return id + 1
+}
+
+func setString(field string, dst *string, value string, changes []bugzilla.HistoryChange) []bugzilla.HistoryChange {
+	if value == "" || *dst == value {
+		return changes
+	}
+	changes = append(changes, bugzilla.HistoryChange{FieldName: field, Removed: *dst, Added: value})
+	*dst = value
+	return changes
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func updateKeywords(current []string, update *bugzilla.BugKeywords) []string {
+	if update.Set != nil {
+		return append([]string{}, update.Set...)
+	}
+	out := []string{}
+	for _, keyword := range current {
+		if !contains(update.Remove, keyword) {
+			out = append(out, keyword)
+		}
+	}
+	for _, keyword := range update.Add {
+		if !contains(out, keyword) {
+			out = append(out, keyword)
+		}
+	}
+	return out
+}
+
+// applyUpdate changes bug the way bugzilla would and returns the history of
+// what changed.
+func applyUpdate(bug *bugzilla.Bug, update bugzilla.BugUpdate) []bugzilla.HistoryChange {
+	changes := []bugzilla.HistoryChange{}
+	changes = setString("status", &bug.Status, update.Status, changes)
+	changes = setString("resolution", &bug.Resolution, update.Resolution, changes)
+	changes = setString("cf_devel_whiteboard", &bug.DevelWhiteboard, update.DevWhiteboard, changes)
+	changes = setString("whiteboard", &bug.Whiteboard, update.Whiteboard, changes)
+	changes = setString("priority", &bug.Priority, update.Priority, changes)
+	changes = setString("severity", &bug.Severity, update.Severity, changes)
+	changes = setString("assigned_to", &bug.AssignedTo, update.AssignedTo, changes)
+
+	if update.TargetRelease != "" {
+		old := strings.Join(bug.TargetRelease, ", ")
+		if old != update.TargetRelease {
+			changes = append(changes, bugzilla.HistoryChange{FieldName: "target_release", Removed: old, Added: update.TargetRelease})
+			bug.TargetRelease = []string{update.TargetRelease}
+		}
+	}
+
+	if update.Keywords != nil {
+		keywords := updateKeywords(bug.Keywords, update.Keywords)
+		removed, added := []string{}, []string{}
+		for _, keyword := range bug.Keywords {
+			if !contains(keywords, keyword) {
+				removed = append(removed, keyword)
+			}
+		}
+		for _, keyword := range keywords {
+			if !contains(bug.Keywords, keyword) {
+				added = append(added, keyword)
+			}
+		}
+		if len(removed) > 0 || len(added) > 0 {
+			changes = append(changes, bugzilla.HistoryChange{FieldName: "keywords", Removed: strings.Join(removed, ", "), Added: strings.Join(added, ", ")})
+		}
+		bug.Keywords = keywords
+	}
+
+	for _, change := range update.Flags {
+		changes = append(changes, updateFlag(bug, change)...)
+	}
+	return changes
+}
+
+// updateFlag sets, changes or with a status of "X" removes a flag.
+func updateFlag(bug *bugzilla.Bug, change bugzilla.FlagChange) []bugzilla.HistoryChange {
+	for i, flag := range bug.Flags {
+		if flag.Name != change.Name {
+			continue
+		}
+		if flag.Status == change.Status {
+			return nil
+		}
+		history := bugzilla.HistoryChange{FieldName: "flagtypes.name", Removed: flag.Name + flag.Status}
+		if change.Status == "X" {
+			bug.Flags = append(bug.Flags[:i], bug.Flags[i+1:]...)
+		} else {
+			bug.Flags[i].Status = change.Status
+			bug.Flags[i].Setter = DefaultUser
+			bug.Flags[i].Requestee = change.Requestee
+			history.Added = change.Name + change.Status
+		}
+		return []bugzilla.HistoryChange{history}
+	}
+	if change.Status == "X" {
+		return nil
+	}
+	bug.Flags = append(bug.Flags, bugzilla.Flag{
+		Name:      change.Name,
+		Status:    change.Status,
+		Setter:    DefaultUser,
+		Requestee: change.Requestee,
+	})
+	return []bugzilla.HistoryChange{{FieldName: "flagtypes.name", Added: change.Name + change.Status}}
+}
@@ -0,0 +1,188 @@
+package fakebugzilla
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/eparis/bugzilla"
+)
+
+const testFixture = `
+bugs:
+- id: 1
+  product: OpenShift Container Platform
+  component: [Networking]
+  status: NEW
+  severity: high
+  target_release: [4.6.0]
+  keywords: [UpcomingSprint]
+  flags:
+  - name: blocker
+    status: "+"
+- id: 2
+  product: OpenShift Container Platform
+  component: [Documentation]
+  status: NEW
+  severity: low
+  target_release: ["---"]
+  depends_on: [1]
+- id: 3
+  product: OpenShift Container Platform
+  component: [Networking]
+  status: CLOSED
+  severity: urgent
+  target_release: [4.7.0]
+comments:
+  1:
+  - id: 10
+    bug_id: 1
+    text: first
+history:
+  1:
+  - when: "2020-10-01T00:00:00Z"
+    who: someone@example.com
+    changes:
+    - field_name: status
+      removed: ASSIGNED
+      added: NEW
+`
+
+func newTestServer(t *testing.T) *Server {
+	f, err := ioutil.TempFile("", "fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(testFixture); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	s, err := NewFromFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSearch(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	s.SetPageSize(1)
+	client := s.Client()
+
+	tests := []struct {
+		name     string
+		query    bugzilla.Query
+		expected []int
+	}{
+		{
+			name:     "all",
+			query:    bugzilla.Query{Product: []string{"OpenShift Container Platform"}},
+			expected: []int{1, 2, 3},
+		},
+		{
+			name:     "status",
+			query:    bugzilla.Query{Status: []string{"NEW", "ASSIGNED"}},
+			expected: []int{1, 2},
+		},
+		{
+			name:     "keywords",
+			query:    bugzilla.Query{Keywords: []string{"UpcomingSprint"}, KeywordsType: "nowords"},
+			expected: []int{2, 3},
+		},
+		{
+			name:     "bug ids",
+			query:    bugzilla.Query{BugIDs: []string{"1", "3"}, BugIDsType: "anyexact"},
+			expected: []int{1, 3},
+		},
+		{
+			name: "negated advanced",
+			query: bugzilla.Query{Advanced: []bugzilla.AdvancedQuery{
+				{Field: "component", Op: "equals", Value: "Documentation", Negate: true},
+				{Field: "flagtypes.name", Op: "substring", Value: "blocker", Negate: true},
+			}},
+			expected: []int{3},
+		},
+		{
+			name: "regexp and isempty",
+			query: bugzilla.Query{Advanced: []bugzilla.AdvancedQuery{
+				{Field: "target_release", Op: "regexp", Value: `^4\.[0-9]+\.0$`},
+				{Field: "dependson", Op: "isempty"},
+			}},
+			expected: []int{1, 3},
+		},
+		{
+			name: "history",
+			query: bugzilla.Query{Advanced: []bugzilla.AdvancedQuery{
+				{Field: "bug_status", Op: "changedafter", Value: "2020-09-01"},
+			}},
+			expected: []int{1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			found, err := client.Search(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []int{}
+			for _, bug := range found {
+				ids = append(ids, bug.ID)
+			}
+			if !reflect.DeepEqual(ids, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, ids)
+			}
+		})
+	}
+
+	_, err := client.Search(bugzilla.Query{Advanced: []bugzilla.AdvancedQuery{{Field: "nope", Op: "equals", Value: "x"}}})
+	if err == nil {
+		t.Errorf("expected an unknown field to be rejected")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	client := s.Client()
+
+	update := bugzilla.BugUpdate{
+		TargetRelease: "---",
+		Keywords:      &bugzilla.BugKeywords{Remove: []string{"UpcomingSprint"}},
+		Flags:         []bugzilla.FlagChange{{Name: "blocker", Status: "-"}},
+		Comment:       &bugzilla.BugComment{Body: "second", Private: true},
+	}
+	if err := client.UpdateBug(1, update); err != nil {
+		t.Fatal(err)
+	}
+
+	bug, err := client.GetBug(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bug.TargetRelease, []string{"---"}) || len(bug.Keywords) != 0 || bug.Flags[0].Status != "-" {
+		t.Errorf("update not applied: %#v", bug)
+	}
+
+	comments, err := client.GetBugComments(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 2 || comments[1].Text != "second" || !comments[1].IsPrivate || comments[1].Id != 11 {
+		t.Errorf("unexpected comments: %#v", comments)
+	}
+
+	history, err := client.GetBugHistory(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || len(history[1].Changes) != 3 || history[1].Who != DefaultUser {
+		t.Errorf("unexpected history: %#v", history)
+	}
+
+	if _, err := client.GetBug(42); !bugzilla.IsNotFound(err) {
+		t.Errorf("expected a missing bug to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package fakebugzilla
+
+import (
+	"sync"
+
+	"github.com/eparis/bugzilla"
+)
+
+// TestClients starts a fake for every fixture it is asked for. Tests use
+// Client as bugs.TestClient, so --test-bug-data serves fixtures from a fake,
+// and Close to stop them all:
+//
+//	func TestMain(m *testing.M) {
+//		fakes := &fakebugzilla.TestClients{}
+//		bugs.TestClient = fakes.Client
+//		code := m.Run()
+//		fakes.Close()
+//		os.Exit(code)
+//	}
+type TestClients struct {
+	lock    sync.Mutex
+	servers []*Server
+}
+
+// Client starts a fake serving the fixture at path and returns a client for it.
+func (c *TestClients) Client(path string) (bugzilla.Client, error) {
+	srv, err := NewFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.servers = append(c.servers, srv)
+	return srv.Client(), nil
+}
+
+// Close stops every fake Client started.
+func (c *TestClients) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, srv := range c.servers {
+		srv.Close()
+	}
+	c.servers = nil
+}
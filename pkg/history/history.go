@@ -0,0 +1,301 @@
+// Package history keeps a time series of per team bug counts so trends can be
+// shown instead of only today's numbers.
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+)
+
+const (
+	dbFlagName   = "history-db"
+	dbFlagDefVal = ""
+	dbFlagUsage  = "Path to a database to record per team bug counts in, unset disables /history"
+
+	intervalFlagName   = "history-interval"
+	intervalFlagDefVal = time.Hour
+	intervalFlagUsage  = "How often to record per team bug counts"
+
+	// keys are written in this format so they sort by time
+	keyFormat = "2006-01-02T15:04:05Z"
+
+	// reconcilePoll is how often the Recorder checks if the bugs were
+	// reconciled yet
+	reconcilePoll = 10 * time.Second
+)
+
+var (
+	bucketName = []byte("team-counts")
+)
+
+// Counts are the numbers recorded for a team at one point in time.
+type Counts struct {
+	Total               int `json:"total"`
+	Urgent              int `json:"urgent"`
+	Blocker             int `json:"blocker"`
+	Untriaged           int `json:"untriaged"`
+	NotReviewedInSprint int `json:"notReviewedInSprint"`
+	Post                int `json:"post"`
+}
+
+// Snapshot is the counts of every team at one point in time.
+type Snapshot struct {
+	Time  time.Time         `json:"time"`
+	Teams map[string]Counts `json:"teams"`
+}
+
+// TeamCounts counts the bugs of every team.
+func TeamCounts(bugData *bugs.BugData) map[string]Counts {
+	out := map[string]Counts{}
+	for team, teamBugs := range bugData.GetTeamMap() {
+		counts := Counts{}
+		for _, bug := range teamBugs {
+			counts.Total++
+			if bug.Severity == "urgent" {
+				counts.Urgent++
+			}
+			if bug.Blocker() {
+				counts.Blocker++
+			}
+			if bug.Untriaged() {
+				counts.Untriaged++
+			}
+			if !bug.ReviewedInSprint() {
+				counts.NotReviewedInSprint++
+			}
+			if bug.Status == "POST" {
+				counts.Post++
+			}
+		}
+		out[team] = counts
+	}
+	return out
+}
+
+// Store records snapshots in a bolt database.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens, or creates, the store at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed opening history at %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record saves the counts of every team at the given time.
+func (s *Store) Record(at time.Time, counts map[string]Counts) error {
+	data, err := json.Marshal(counts)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Put([]byte(at.UTC().Format(keyFormat)), data)
+	})
+}
+
+// Range returns every snapshot taken from `from` up to and including `to`,
+// oldest first.
+func (s *Store) Range(from, to time.Time) ([]Snapshot, error) {
+	out := []Snapshot{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketName).Cursor()
+		max := []byte(to.UTC().Format(keyFormat))
+		for k, v := c.Seek([]byte(from.UTC().Format(keyFormat))); k != nil && string(k) <= string(max); k, v = c.Next() {
+			at, err := time.Parse(keyFormat, string(k))
+			if err != nil {
+				return err
+			}
+			snapshot := Snapshot{Time: at}
+			if err := json.Unmarshal(v, &snapshot.Teams); err != nil {
+				return fmt.Errorf("unable to decode snapshot %s: %v", k, err)
+			}
+			out = append(out, snapshot)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Latest returns when the newest snapshot was taken, zero if there is none.
+func (s *Store) Latest() (time.Time, error) {
+	var latest time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		k, _ := tx.Bucket(bucketName).Cursor().Last()
+		if k == nil {
+			return nil
+		}
+		var err error
+		latest, err = time.Parse(keyFormat, string(k))
+		return err
+	})
+	return latest, err
+}
+
+// Recorder records the counts of bugData every interval. Restarts do not
+// reset the interval, it is measured from the newest snapshot in the store.
+// Nothing is recorded until bugData was reconciled, before that it holds no
+// bugs or only a snapshot of an earlier run's.
+func (s *Store) Recorder(errs chan error, bugData *bugs.BugData, interval time.Duration) {
+	go func() {
+		for true {
+			if !bugData.Reconciled() {
+				time.Sleep(reconcilePoll)
+				continue
+			}
+			latest, err := s.Latest()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if wait := interval - time.Since(latest); wait > 0 {
+				time.Sleep(wait)
+				continue
+			}
+			if err := s.Record(time.Now(), TeamCounts(bugData)); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+}
+
+// TeamPoint is the counts of a single team at one point in time.
+type TeamPoint struct {
+	Time time.Time `json:"time"`
+	Counts
+}
+
+// parseTimeParam parses the RFC3339 or YYYY-MM-DD query parameter name. With
+// endOfDay a date means the last moment of that day instead of its start, so
+// it includes everything recorded that day.
+func parseTimeParam(r *http.Request, name string, def time.Time, endOfDay bool) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s must be RFC3339 or YYYY-MM-DD, got %q", name, value)
+}
+
+// Handler serves /history?team=X&from=&to=. from defaults to 30 days ago and
+// to to now, both are inclusive so to=YYYY-MM-DD includes that whole day.
+// With a team it returns that team's counts over time, without one it returns
+// the snapshots of every team.
+func (s *Store) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		from, err := parseTimeParam(r, "from", now.AddDate(0, 0, -30), false)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		to, err := parseTimeParam(r, "to", now, true)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		snapshots, err := s.Range(from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var out interface{} = snapshots
+		if team := r.URL.Query().Get("team"); team != "" {
+			points := []TeamPoint{}
+			for _, snapshot := range snapshots {
+				if counts, ok := snapshot.Teams[team]; ok {
+					points = append(points, TeamPoint{Time: snapshot.Time, Counts: counts})
+				}
+			}
+			out = points
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			fmt.Printf("Unable to encode: %v: %v", out, err)
+		}
+	}
+}
+
+// samePath is true if a and b name the same file, even if only one of them
+// exists yet.
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA == nil && errB == nil && absA == absB {
+		return true
+	}
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// Setup opens the store named by --history-db and starts recording bugData.
+// It returns nil if --history-db is not set. The store can not share the
+// --bug-snapshot database, bolt only lets one of them open it.
+func Setup(cmd *cobra.Command, errs chan error, bugData *bugs.BugData) (*Store, error) {
+	path, err := cmd.Flags().GetString(dbFlagName)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, nil
+	}
+	snapshotPath, err := bugs.SnapshotPath(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if snapshotPath != "" && samePath(path, snapshotPath) {
+		return nil, fmt.Errorf("--%s must not be the --bug-snapshot database %s", dbFlagName, snapshotPath)
+	}
+	interval, err := cmd.Flags().GetDuration(intervalFlagName)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	s.Recorder(errs, bugData, interval)
+	return s, nil
+}
+
+func AddFlags(cmd *cobra.Command) {
+	cmd.Flags().String(dbFlagName, dbFlagDefVal, dbFlagUsage)
+	cmd.Flags().Duration(intervalFlagName, intervalFlagDefVal, intervalFlagUsage)
+}
@@ -0,0 +1,124 @@
+package history
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+)
+
+func TestHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := Open(filepath.Join(dir, "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	day := func(d int) time.Time {
+		return time.Date(2020, 10, d, 12, 0, 0, 0, time.UTC)
+	}
+	for d := 1; d <= 3; d++ {
+		counts := map[string]Counts{
+			"Networking": {Total: 10 - d, Urgent: 1},
+		}
+		if d == 3 {
+			counts["Etcd"] = Counts{Total: 1}
+		}
+		if err := s.Record(day(d), counts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if latest, err := s.Latest(); err != nil || !latest.Equal(day(3)) {
+		t.Errorf("expected the latest snapshot at %v, got %v: %v", day(3), latest, err)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []TeamPoint
+	}{
+		{
+			name:  "range",
+			query: "?team=Networking&from=2020-10-02&to=2020-10-03T12:00:00Z",
+			expected: []TeamPoint{
+				{Time: day(2), Counts: Counts{Total: 8, Urgent: 1}},
+				{Time: day(3), Counts: Counts{Total: 7, Urgent: 1}},
+			},
+		},
+		{
+			name:  "date only to includes the whole day",
+			query: "?team=Networking&from=2020-10-01&to=2020-10-02",
+			expected: []TeamPoint{
+				{Time: day(1), Counts: Counts{Total: 9, Urgent: 1}},
+				{Time: day(2), Counts: Counts{Total: 8, Urgent: 1}},
+			},
+		},
+		{
+			name:     "team missing from older snapshots",
+			query:    "?team=Etcd&from=2020-10-01",
+			expected: []TeamPoint{{Time: day(3), Counts: Counts{Total: 1}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.Handler()(w, httptest.NewRequest(http.MethodGet, "/history"+test.query, nil))
+			got := []TeamPoint{}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	s.Handler()(w, httptest.NewRequest(http.MethodGet, "/history?from=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected a bad time to be rejected, got %d", w.Code)
+	}
+}
+
+func TestSetupRejectsSnapshotDB(t *testing.T) {
+	cmd := &cobra.Command{}
+	bugs.AddFlags(cmd)
+	AddFlags(cmd)
+	cmd.Flags().Set("bug-snapshot", "bugs.db")
+	cmd.Flags().Set(dbFlagName, "./bugs.db")
+	if _, err := Setup(cmd, make(chan error, 1), nil); err == nil {
+		t.Errorf("expected --%s sharing the snapshot database to be rejected", dbFlagName)
+	}
+}
+
+func TestRecorderWaitsForReconcile(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	errs := make(chan error, 1)
+	s.Recorder(errs, &bugs.BugData{}, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	latest, err := s.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !latest.IsZero() {
+		t.Errorf("expected nothing recorded before the bugs were reconciled, got a snapshot at %v", latest)
+	}
+}
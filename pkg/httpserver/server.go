@@ -0,0 +1,271 @@
+// Package httpserver is the HTTP server shared by the services. Besides the
+// service's own handlers it serves /healthz, /readyz, /version and /metrics,
+// logs every request and shuts down gracefully on SIGTERM. It starts listening
+// before the service loads its data, so the probes are answered while it does.
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"github.com/openshift/bugzilla-tools/pkg/version"
+)
+
+const (
+	listenFlagName = "listen"
+
+	accessLogFlagName   = "access-log"
+	accessLogFlagDefVal = true
+
+	// shutdownTimeout is how long requests in flight get to finish
+	shutdownTimeout = 30 * time.Second
+)
+
+var (
+	// exit ends the process when it is stopped before Wait
+	exit = os.Exit
+
+	// builtins are served from Start, everything else only once the
+	// service is Waiting
+	builtins = map[string]bool{
+		"/healthz": true,
+		"/readyz":  true,
+		"/version": true,
+		"/metrics": true,
+	}
+)
+
+type readyCheck struct {
+	name  string
+	ready func() bool
+}
+
+// Server serves a service's handlers along with /healthz, /readyz, /version
+// and /metrics.
+type Server struct {
+	mux    *http.ServeMux
+	server *http.Server
+	stop   chan os.Signal
+	// waiting is closed by Wait, which takes over handling stop
+	waiting chan struct{}
+
+	lock    sync.Mutex
+	checks  []readyCheck
+	serving bool
+}
+
+// New returns a server listening at --listen. Nothing is served until Start.
+func New(cmd *cobra.Command) (*Server, error) {
+	listen, err := cmd.Flags().GetString(listenFlagName)
+	if err != nil {
+		return nil, err
+	}
+	accessLog, err := cmd.Flags().GetBool(accessLogFlagName)
+	if err != nil {
+		return nil, err
+	}
+	return newServer(listen, accessLog), nil
+}
+
+func newServer(listen string, accessLog bool) *Server {
+	s := &Server{mux: http.NewServeMux()}
+	s.mux.HandleFunc("/healthz", healthz)
+	s.mux.HandleFunc("/readyz", s.readyz)
+	s.mux.HandleFunc("/version", versionHandler)
+	s.mux.Handle("/metrics", promhttp.Handler())
+
+	var handler http.Handler = http.HandlerFunc(s.serveHTTP)
+	if accessLog {
+		handler = logRequests(handler)
+	}
+	s.server = &http.Server{
+		Addr:    listen,
+		Handler: handler,
+	}
+	return s
+}
+
+// serveHTTP only answers the builtins until the service's own handlers are
+// registered, the rest get a 503 like they would with no ready pod.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !builtins[r.URL.Path] && !s.isServing() {
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) isServing() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.serving
+}
+
+// Handle registers the handler for the pattern, like http.ServeMux.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// ReadyWhen makes /readyz fail until ready returns true, like until the first
+// Reconcile of the data the service serves.
+func (s *Server) ReadyWhen(name string, ready func() bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.checks = append(s.checks, readyCheck{name: name, ready: ready})
+}
+
+// Addr is the address the server listens at.
+func (s *Server) Addr() string {
+	return s.server.Addr
+}
+
+// Start listens, sending any error serving to errs. Until Wait only the
+// builtins are served, so it should be called before the service loads its
+// data, and the service's handlers registered once it has. SIGTERM or SIGINT
+// before Wait exits right away, nothing is being served yet which needs to
+// finish.
+func (s *Server) Start(errs chan error) {
+	s.stop = make(chan os.Signal, 1)
+	s.waiting = make(chan struct{})
+	signal.Notify(s.stop, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-s.stop:
+			select {
+			case <-s.waiting:
+				// Wait started meanwhile and shuts down gracefully
+				s.stop <- sig
+				return
+			default:
+			}
+			fmt.Printf("Received %v while starting, exiting\n", sig)
+			s.server.Close()
+			exit(0)
+		case <-s.waiting:
+		}
+	}()
+	go func() {
+		if err := s.server.ListenAndServe(); err != http.ErrServerClosed {
+			errs <- err
+		}
+	}()
+	fmt.Printf("Serving at %s\n", s.Addr())
+}
+
+// Wait serves every handler until SIGTERM or SIGINT, when it waits for
+// requests in flight to finish, or until an error arrives on errs, which it
+// returns.
+func (s *Server) Wait(errs chan error) error {
+	defer signal.Stop(s.stop)
+	s.lock.Lock()
+	s.serving = true
+	s.lock.Unlock()
+	close(s.waiting)
+
+	select {
+	case <-s.stop:
+		fmt.Println("Shutting down...")
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return s.server.Shutdown(ctx)
+	case err := <-errs:
+		fmt.Println("Server error:", err.Error())
+		s.server.Close()
+		return err
+	}
+}
+
+// healthz is ok as long as the process can serve requests.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz is ok once the server is Waiting and every ReadyWhen check is ok.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	checks := append([]readyCheck{}, s.checks...)
+	serving := s.serving
+	s.lock.Unlock()
+
+	if !serving {
+		http.Error(w, "not ready: starting", http.StatusServiceUnavailable)
+		return
+	}
+	notReady := []string{}
+	for _, check := range checks {
+		if !check.ready() {
+			notReady = append(notReady, check.name)
+		}
+	}
+	if len(notReady) > 0 {
+		http.Error(w, fmt.Sprintf("not ready: %s", strings.Join(notReady, ", ")), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(version.Get()); err != nil {
+		fmt.Printf("Unable to encode version: %v\n", err)
+	}
+}
+
+// statusRecorder remembers the status and size of a response for the access
+// log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// logRequests logs every request except the probes, which would drown out
+// everything else.
+func logRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		klog.Infof("%s %s %s %d %d %v %q", r.RemoteAddr, r.Method, r.URL.RequestURI(), recorder.status, recorder.bytes, time.Since(start).Round(time.Millisecond), r.UserAgent())
+	})
+}
+
+// AddFlags adds --listen, defaulting to defaultListen, and --access-log.
+func AddFlags(cmd *cobra.Command, defaultListen string) {
+	cmd.Flags().String(listenFlagName, defaultListen, "Address to serve HTTP at")
+	cmd.Flags().Bool(accessLogFlagName, accessLogFlagDefVal, "Log every HTTP request other than /healthz and /readyz")
+}
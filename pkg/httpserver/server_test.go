@@ -0,0 +1,87 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestEndpoints(t *testing.T) {
+	s := newServer(":0", true)
+	ready := false
+	s.ReadyWhen("data", func() bool { return ready })
+	s.Handle("/teams", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		path string
+		// serving is whether the server is Waiting, after the service
+		// loaded its data and registered its handlers
+		serving bool
+		ready   bool
+		status  int
+	}{
+		{"/healthz", false, false, http.StatusOK},
+		{"/readyz", false, true, http.StatusServiceUnavailable},
+		{"/version", false, false, http.StatusOK},
+		{"/metrics", false, false, http.StatusOK},
+		{"/teams", false, true, http.StatusServiceUnavailable},
+		{"/healthz", true, false, http.StatusOK},
+		{"/readyz", true, false, http.StatusServiceUnavailable},
+		{"/readyz", true, true, http.StatusOK},
+		{"/teams", true, false, http.StatusTeapot},
+	}
+	for _, test := range tests {
+		ready = test.ready
+		s.serving = test.serving
+		w := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if w.Code != test.status {
+			t.Errorf("%s serving=%v ready=%v: expected %d got %d: %s", test.path, test.serving, test.ready, test.status, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestStop(t *testing.T) {
+	exited := make(chan int, 1)
+	exit = func(code int) { exited <- code }
+	defer func() { exit = os.Exit }()
+
+	// while the service loads its data
+	s := newServer("127.0.0.1:0", false)
+	s.Start(make(chan error, 1))
+	s.stop <- syscall.SIGTERM
+	select {
+	case code := <-exited:
+		if code != 0 {
+			t.Errorf("expected to exit with 0, got %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a signal before Wait to exit")
+	}
+
+	// once it is serving
+	s = newServer("127.0.0.1:0", false)
+	s.Start(make(chan error, 1))
+	done := make(chan error, 1)
+	go func() { done <- s.Wait(make(chan error, 1)) }()
+	<-s.waiting
+	s.stop <- syscall.SIGTERM
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected Wait to return on a signal")
+	}
+	select {
+	case <-exited:
+		t.Errorf("expected a signal during Wait to shut down instead of exiting")
+	default:
+	}
+}
@@ -2,16 +2,68 @@ package metrics
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/openshift/bugzilla-tools/pkg/bugs"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	sloAPI "github.com/openshift/bugzilla-tools/pkg/slo/api"
+)
+
+const (
+	perBugFlagName   = "per-bug-metrics"
+	perBugFlagDefVal = false
+	perBugFlagUsage  = "Also publish the bugs gauge with one series per bug. This creates a lot of series"
+
+	ageIntervalFlagName   = "bug-age-interval"
+	ageIntervalFlagDefVal = time.Duration(0)
+	ageIntervalFlagUsage  = "How often to publish percentiles of how old every team's bugs are. This fetches the history of every bug, 0 disables it"
 )
 
 var (
 	lastGauges = map[int]prometheus.Labels{}
+	lastCounts = map[[6]string]int{}
+	lastSLOs   = map[[2]string]bool{}
+	// teams which have bug age gauges
+	lastAgeTeams = map[string]bool{}
+
+	bugCount = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bug_count",
+		Help: "Open bugs by team, severity, priority, status, blocker flag and target release",
+	}, []string{"team", "severity", "priority", "status", "blocker", "target_release"})
+
+	sloCurrent = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "team_slo_current",
+		Help: "Where a team currently is for each SLO",
+	}, []string{"team", "slo"})
+	sloObligation = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "team_slo_obligation",
+		Help: "What a team is allowed for each SLO",
+	}, []string{"team", "slo"})
+	sloFailing = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "team_slo_failing",
+		Help: "1 if a team fails any of its SLOs",
+	}, []string{"team"})
+
+	// AgeQuantiles are the percentiles published for bug ages, 1 is the oldest bug
+	AgeQuantiles = []float64{0.5, 0.9, 1}
+
+	bugAge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bug_age_seconds",
+		Help: "Percentiles of how long ago a team's bugs were created",
+	}, []string{"team", "quantile"})
+	bugInStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bug_time_in_status_seconds",
+		Help: "Percentiles of how long a team's bugs have been in their current status",
+	}, []string{"team", "quantile"})
+	bugSinceTriage = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bug_time_since_triage_seconds",
+		Help: "Percentiles of how long ago a team's bugs had their severity, priority, keywords or flags changed",
+	}, []string{"team", "quantile"})
 )
 
 func labelsFromBug(bug *bugs.Bug, team string) prometheus.Labels {
@@ -21,8 +73,25 @@ func labelsFromBug(bug *bugs.Bug, team string) prometheus.Labels {
 		"status":         bug.Status,
 		"severity":       bug.Severity,
 		"keywords":       strings.Join(bug.Keywords, ","),
-		"target_release": bug.TargetRelease[0],
+		"target_release": targetRelease(bug),
+	}
+}
+
+func targetRelease(bug *bugs.Bug) string {
+	if len(bug.TargetRelease) == 0 {
+		return ""
+	}
+	return bug.TargetRelease[0]
+}
+
+// blockerState is the status of the blocker flag, or "none" if it is not set
+func blockerState(bug *bugs.Bug) string {
+	for _, flag := range bug.Flags {
+		if flag.Name == bugs.BlockerFlagName {
+			return flag.Status
+		}
 	}
+	return "none"
 }
 
 func updateGauge(bugs bugs.TeamMap, bugGauge *prometheus.GaugeVec) {
@@ -44,6 +113,75 @@ func updateGauge(bugs bugs.TeamMap, bugGauge *prometheus.GaugeVec) {
 	lastGauges = nextGauges
 }
 
+// updateCounts replaces every bug_count series. Combinations which no longer
+// have any bugs are dropped rather than left at their old value.
+func updateCounts(teamMap bugs.TeamMap) {
+	counts := map[[6]string]int{}
+	for team, teamBugs := range teamMap {
+		for _, bug := range teamBugs {
+			counts[[6]string{team, bug.Severity, bug.Priority, bug.Status, blockerState(bug), targetRelease(bug)}]++
+		}
+	}
+	for labels, count := range counts {
+		bugCount.WithLabelValues(labels[:]...).Set(float64(count))
+		delete(lastCounts, labels)
+	}
+	for labels := range lastCounts {
+		bugCount.DeleteLabelValues(labels[:]...)
+	}
+	lastCounts = counts
+}
+
+// UpdateSLOs publishes the SLO results of every team.
+func UpdateSLOs(results sloAPI.TeamsResults) {
+	// every team gets a new value, so a team which is gone is dropped
+	sloFailing.Reset()
+	next := map[[2]string]bool{}
+	for team, result := range results {
+		for _, slo := range result.Results {
+			sloCurrent.WithLabelValues(team, slo.Name).Set(float64(slo.Current))
+			sloObligation.WithLabelValues(team, slo.Name).Set(float64(slo.Obligation))
+			next[[2]string{team, slo.Name}] = true
+			delete(lastSLOs, [2]string{team, slo.Name})
+		}
+		failing := 0.0
+		if result.Failing {
+			failing = 1
+		}
+		sloFailing.WithLabelValues(team).Set(failing)
+	}
+	for labels := range lastSLOs {
+		sloCurrent.DeleteLabelValues(labels[:]...)
+		sloObligation.DeleteLabelValues(labels[:]...)
+	}
+	lastSLOs = next
+}
+
+// UpdateAges publishes the age percentiles of every team.
+func UpdateAges(percentiles map[string]bugs.AgePercentiles) {
+	for team := range lastAgeTeams {
+		if _, ok := percentiles[team]; ok {
+			continue
+		}
+		for _, q := range AgeQuantiles {
+			quantile := strconv.FormatFloat(q, 'f', -1, 64)
+			bugAge.DeleteLabelValues(team, quantile)
+			bugInStatus.DeleteLabelValues(team, quantile)
+			bugSinceTriage.DeleteLabelValues(team, quantile)
+		}
+	}
+	lastAgeTeams = map[string]bool{}
+	for team, p := range percentiles {
+		lastAgeTeams[team] = true
+		for _, q := range AgeQuantiles {
+			quantile := strconv.FormatFloat(q, 'f', -1, 64)
+			bugAge.WithLabelValues(team, quantile).Set(p.Age[q].Seconds())
+			bugInStatus.WithLabelValues(team, quantile).Set(p.InStatus[q].Seconds())
+			bugSinceTriage.WithLabelValues(team, quantile).Set(p.SinceTriage[q].Seconds())
+		}
+	}
+}
+
 func createGauge() *prometheus.GaugeVec {
 	ops := prometheus.GaugeOpts{
 		Name: "bugs",
@@ -53,20 +191,53 @@ func createGauge() *prometheus.GaugeVec {
 	return promauto.NewGaugeVec(ops, requiredLabels)
 }
 
-// Create a guague for every team.
-func Setup(errs chan error, bugData *bugs.BugData) {
-	bugGauge := createGauge()
+// Setup publishes bug_count for every team and, with --per-bug-metrics, the
+// bugs gauge with a series for every bug.
+func Setup(cmd *cobra.Command, errs chan error, bugData *bugs.BugData) error {
+	perBug, err := cmd.Flags().GetBool(perBugFlagName)
+	if err != nil {
+		return err
+	}
+	var bugGauge *prometheus.GaugeVec
+	if perBug {
+		bugGauge = createGauge()
+	}
 	go func() {
 		for true {
 			bugs := bugData.GetTeamMap()
 			fmt.Printf("Found %d teams in bugMap!\n", len(bugs))
-			// Don't publish data until we actually get a response from BZ
-			if len(bugs) == 0 {
-				time.Sleep(1 * time.Second)
-				continue
+			updateCounts(bugs)
+			if bugGauge != nil {
+				updateGauge(bugs, bugGauge)
 			}
-			updateGauge(bugs, bugGauge)
 			time.Sleep(1 * time.Minute)
 		}
 	}()
+
+	ageInterval, err := cmd.Flags().GetDuration(ageIntervalFlagName)
+	if err != nil {
+		return err
+	}
+	if ageInterval > 0 {
+		go func() {
+			for true {
+				ages, err := bugData.GetAges(time.Now())
+				if err != nil {
+					// publish what we have, a few missing histories should
+					// not hide every team's ages
+					fmt.Printf("Unable to get the age of every bug: %v\n", err)
+				}
+				if len(ages) > 0 {
+					UpdateAges(bugs.TeamAgePercentiles(ages, AgeQuantiles))
+				}
+				time.Sleep(ageInterval)
+			}
+		}()
+	}
+	return nil
+}
+
+func AddFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool(perBugFlagName, perBugFlagDefVal, perBugFlagUsage)
+	cmd.Flags().Duration(ageIntervalFlagName, ageIntervalFlagDefVal, ageIntervalFlagUsage)
 }
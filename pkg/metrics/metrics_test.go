@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eparis/bugzilla"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/openshift/bugzilla-tools/pkg/bugs"
+	sloAPI "github.com/openshift/bugzilla-tools/pkg/slo/api"
+)
+
+// gathered returns the value of every series of the metric by its label values
+func gathered(t *testing.T, name string) map[string]float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			key := ""
+			for _, label := range metric.GetLabel() {
+				key += label.GetValue() + "/"
+			}
+			out[key] = metric.GetGauge().GetValue()
+		}
+	}
+	return out
+}
+
+func TestUpdateCounts(t *testing.T) {
+	bug := func(id int, severity string, flags ...bugzilla.Flag) *bugs.Bug {
+		return &bugs.Bug{ID: id, Severity: severity, Priority: "high", Status: "NEW", TargetRelease: []string{"4.7.0"}, Flags: flags}
+	}
+	blocker := bugzilla.Flag{Name: bugs.BlockerFlagName, Status: bugs.FlagTrue}
+
+	updateCounts(bugs.TeamMap{
+		"Networking": {bug(1, "urgent", blocker), bug(2, "urgent", blocker), bug(3, "low")},
+	})
+	// labels are sorted by name: blocker, priority, severity, status, target_release, team
+	expected := map[string]float64{
+		"+/high/urgent/NEW/4.7.0/Networking/": 2,
+		"none/high/low/NEW/4.7.0/Networking/": 1,
+	}
+	if got := gathered(t, "bug_count"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	updateCounts(bugs.TeamMap{
+		"Networking": {bug(3, "low")},
+	})
+	expected = map[string]float64{
+		"none/high/low/NEW/4.7.0/Networking/": 1,
+	}
+	if got := gathered(t, "bug_count"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected the fixed blockers to be dropped, got %v", got)
+	}
+}
+
+func TestUpdateSLOs(t *testing.T) {
+	UpdateSLOs(sloAPI.TeamsResults{
+		"Networking": {Name: "Networking", Failing: true, Results: []sloAPI.Result{{Name: "blockers", Current: 3, Obligation: 1}}},
+		"Storage":    {Name: "Storage"},
+	})
+	expected := map[string]float64{"Networking/": 1, "Storage/": 0}
+	if got := gathered(t, "team_slo_failing"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	UpdateSLOs(sloAPI.TeamsResults{
+		"Networking": {Name: "Networking"},
+	})
+	expected = map[string]float64{"Networking/": 0}
+	if got := gathered(t, "team_slo_failing"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected the removed team to be dropped, got %v", got)
+	}
+	if got := gathered(t, "team_slo_current"); len(got) != 0 {
+		t.Errorf("expected the removed SLO to be dropped, got %v", got)
+	}
+}
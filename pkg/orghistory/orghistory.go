@@ -0,0 +1,291 @@
+// Package orghistory records every version of the org data so changes to team
+// ownership, channels and sizes can be explained afterwards.
+package orghistory
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+const (
+	dbFlagName   = "org-history-db"
+	dbFlagDefVal = ""
+	dbFlagUsage  = "Path to a database to record every version of the org data in, unset disables /teams/history and /teams/diff"
+)
+
+var (
+	bucketName = []byte("org-data-versions")
+)
+
+// Version is the org data as it was from Time until the next version.
+type Version struct {
+	Version int            `json:"version"`
+	Time    time.Time      `json:"time"`
+	OrgData *teams.OrgData `json:"orgData,omitempty"`
+}
+
+// Store records versions in a bolt database.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens, or creates, the store at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed opening org data history at %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// keys are the big endian version so they sort in order
+func key(version int) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(version))
+	return k
+}
+
+func decode(k, v []byte) (Version, error) {
+	version := Version{}
+	if err := json.Unmarshal(v, &version); err != nil {
+		return version, fmt.Errorf("unable to decode org data version %d: %v", binary.BigEndian.Uint64(k), err)
+	}
+	return version, nil
+}
+
+// Record saves the org data as a new version if it differs from the latest
+// one. It returns the number of the latest version afterwards.
+func (s *Store) Record(at time.Time, orgData *teams.OrgData) (int, error) {
+	latest := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketName)
+		if k, v := b.Cursor().Last(); k != nil {
+			last, err := decode(k, v)
+			if err != nil {
+				return err
+			}
+			latest = last.Version
+			if last.OrgData.Equal(orgData) {
+				return nil
+			}
+		}
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		version := Version{Version: int(seq), Time: at.UTC(), OrgData: orgData}
+		data, err := json.Marshal(version)
+		if err != nil {
+			return err
+		}
+		latest = version.Version
+		return b.Put(key(version.Version), data)
+	})
+	return latest, err
+}
+
+// All returns every version, oldest first.
+func (s *Store) All() ([]Version, error) {
+	out := []Version{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).ForEach(func(k, v []byte) error {
+			version, err := decode(k, v)
+			if err != nil {
+				return err
+			}
+			out = append(out, version)
+			return nil
+		})
+	})
+	return out, err
+}
+
+// Recorder records the org data now and every time it is reconciled into
+// something new.
+func (s *Store) Recorder(orgData *teams.OrgData) error {
+	if _, err := s.Record(time.Now(), orgData); err != nil {
+		return err
+	}
+	orgData.OnChange(func(orgData *teams.OrgData) {
+		version, err := s.Record(time.Now(), orgData)
+		if err != nil {
+			fmt.Printf("Unable to record the org data: %v\n", err)
+			return
+		}
+		fmt.Printf("Recorded org data version %d\n", version)
+	})
+	return nil
+}
+
+// HistoryEntry is a version and how it differs from the one before it.
+type HistoryEntry struct {
+	Version int              `json:"version"`
+	Time    time.Time        `json:"time"`
+	Changes []teams.TeamDiff `json:"changes"`
+}
+
+// History returns every version and how it changed the teams, oldest first.
+func History(versions []Version) []HistoryEntry {
+	out := make([]HistoryEntry, 0, len(versions))
+	previous := &teams.OrgData{}
+	for _, version := range versions {
+		out = append(out, HistoryEntry{
+			Version: version.Version,
+			Time:    version.Time,
+			Changes: teams.Diff(previous, version.OrgData),
+		})
+		previous = version.OrgData
+	}
+	return out
+}
+
+// find returns the index of the version named by value, either a version
+// number or a time the version was in effect at. It is -1 if there is no
+// such version, like a time before the first version.
+func find(versions []Version, value string) (int, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		for i, version := range versions {
+			if version.Version == n {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("version %d not found", n)
+	}
+	var at time.Time
+	var err error
+	for _, format := range []string{time.RFC3339, "2006-01-02"} {
+		if at, err = time.Parse(format, value); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%q must be a version, RFC3339 or YYYY-MM-DD", value)
+	}
+	found := -1
+	for i, version := range versions {
+		if version.Time.After(at) {
+			break
+		}
+		found = i
+	}
+	return found, nil
+}
+
+func writeJSON(w http.ResponseWriter, out interface{}) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		fmt.Printf("Unable to encode: %v: %v", out, err)
+	}
+}
+
+// HistoryHandler serves /teams/history, every version of the org data, when
+// it was loaded and how it changed the teams.
+func (s *Store) HistoryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		versions, err := s.All()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, History(versions))
+	}
+}
+
+// Diff is how the teams changed between two versions.
+type Diff struct {
+	From    int              `json:"from"`
+	To      int              `json:"to"`
+	Changes []teams.TeamDiff `json:"changes"`
+}
+
+// DiffHandler serves /teams/diff?from=&to=. Both are a version or a time, to
+// defaults to the latest version and from to the version before to. A from
+// before the first version compares against no teams at all.
+func (s *Store) DiffHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		versions, err := s.All()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(versions) == 0 {
+			http.Error(w, "no org data recorded yet", http.StatusNotFound)
+			return
+		}
+
+		to := len(versions) - 1
+		if value := r.URL.Query().Get("to"); value != "" {
+			if to, err = find(versions, value); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if to < 0 {
+				http.Error(w, fmt.Sprintf("no org data recorded at %s", value), http.StatusNotFound)
+				return
+			}
+		}
+		from := to - 1
+		if value := r.URL.Query().Get("from"); value != "" {
+			if from, err = find(versions, value); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		out := Diff{To: versions[to].Version}
+		fromOrgData := &teams.OrgData{}
+		if from >= 0 {
+			out.From = versions[from].Version
+			fromOrgData = versions[from].OrgData
+		}
+		out.Changes = teams.Diff(fromOrgData, versions[to].OrgData)
+		writeJSON(w, out)
+	}
+}
+
+// Setup opens the store named by --org-history-db and records every version of
+// orgData in it. It returns nil if --org-history-db is not set.
+func Setup(cmd *cobra.Command, orgData *teams.OrgData) (*Store, error) {
+	path, err := cmd.Flags().GetString(dbFlagName)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, nil
+	}
+	s, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Recorder(orgData); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func AddFlags(cmd *cobra.Command) {
+	cmd.Flags().String(dbFlagName, dbFlagDefVal, dbFlagUsage)
+}
@@ -0,0 +1,125 @@
+package orghistory
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	sloAPI "github.com/openshift/bugzilla-tools/pkg/slo/api"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+func TestDiffHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orghistory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := Open(filepath.Join(dir, "orghistory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	day := func(d int) time.Time {
+		return time.Date(2020, 10, d, 12, 0, 0, 0, time.UTC)
+	}
+	v1 := &teams.OrgData{Teams: map[string]teams.TeamInfo{
+		"Networking": {Name: "Networking", Components: []string{"Networking"}, SlackChan: "#net", MemberCount: 5},
+		"Etcd":       {Name: "Etcd", Components: []string{"Etcd"}},
+	}}
+	v2 := &teams.OrgData{Teams: map[string]teams.TeamInfo{
+		"Networking": {
+			Name:        "Networking",
+			Components:  []string{"Networking", "DNS"},
+			SlackChan:   "#networking",
+			Managers:    []string{"boss@example.com"},
+			MemberCount: 6,
+			SLO:         map[string]sloAPI.Data{sloAPI.Urgent: {Count: 2}},
+		},
+		"Storage": {Name: "Storage", Components: []string{"Storage"}},
+	}}
+	for i, orgData := range []*teams.OrgData{v1, v1, v2} {
+		if _, err := s.Record(day(i+1), orgData); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions, err := s.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[1].Version != 2 || !versions[1].Time.Equal(day(3)) {
+		t.Fatalf("expected an unchanged org data to not be recorded, got %v", versions)
+	}
+
+	v2Changes := []teams.TeamDiff{
+		{Team: "Etcd", Removed: true, ComponentsRemoved: []string{"Etcd"}},
+		{
+			Team:            "Networking",
+			ComponentsAdded: []string{"DNS"},
+			ManagersAdded:   []string{"boss@example.com"},
+			SlackChan:       &teams.StringChange{From: "#net", To: "#networking"},
+			MemberCount:     &teams.IntChange{From: 5, To: 6},
+			SLOsChanged:     []string{sloAPI.Urgent},
+		},
+		{Team: "Storage", Added: true, ComponentsAdded: []string{"Storage"}},
+	}
+	tests := []struct {
+		name     string
+		query    string
+		expected Diff
+	}{
+		{
+			name:     "latest",
+			expected: Diff{From: 1, To: 2, Changes: v2Changes},
+		},
+		{
+			name:     "by time",
+			query:    "?from=2020-10-02&to=2020-10-03T12:00:00Z",
+			expected: Diff{From: 1, To: 2, Changes: v2Changes},
+		},
+		{
+			name:  "from before the first version",
+			query: "?from=2020-09-01&to=1",
+			expected: Diff{To: 1, Changes: []teams.TeamDiff{
+				{Team: "Etcd", Added: true, ComponentsAdded: []string{"Etcd"}},
+				{Team: "Networking", Added: true, ComponentsAdded: []string{"Networking"}, SlackChan: &teams.StringChange{To: "#net"}, MemberCount: &teams.IntChange{To: 5}},
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.DiffHandler()(w, httptest.NewRequest(http.MethodGet, "/teams/diff"+test.query, nil))
+			got := Diff{}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, got)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	s.DiffHandler()(w, httptest.NewRequest(http.MethodGet, "/teams/diff?to=7", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown version to be rejected, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.HistoryHandler()(w, httptest.NewRequest(http.MethodGet, "/teams/history", nil))
+	history := []HistoryEntry{}
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || !reflect.DeepEqual(history[1].Changes, v2Changes) {
+		t.Errorf("unexpected history %+v", history)
+	}
+}
@@ -0,0 +1,250 @@
+// Package rules loads bug automation rules written as templated YAML.
+//
+// Every .yaml file in a rules directory holds one Rule. Before it is parsed
+// the file is executed as a text/template with Vars, so rules can refer to
+// values like {{ .CurrentVersion }} or
+// {{ requiredList .Releases.ShippedZeroTargets }}. A rule may extend one or
+// more other rules, normally abstract rules which only exist to be shared, and
+// inherits their query and update. Concrete rules are resolved into
+// api.BugActions.
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/eparis/bugzilla"
+	"github.com/ghodss/yaml"
+
+	"github.com/openshift/bugzilla-tools/pkg/api"
+	"github.com/openshift/bugzilla-tools/pkg/teams"
+)
+
+// Vars are the values available to the rule templates.
+type Vars struct {
+	// CurrentVersion is the x.y release currently in development, eg "4.7"
+	CurrentVersion string
+	// Releases holds the current, shipped and z-stream target releases
+	Releases teams.ReleaseTargets
+	// TestBugs limits every query to only these bug IDs if set
+	TestBugs []string
+}
+
+// NewVars fills in the release information from the org data.
+func NewVars(orgData *teams.OrgData, now time.Time) (Vars, error) {
+	rt, err := orgData.GetReleaseTargets(now)
+	if err != nil {
+		return Vars{}, err
+	}
+	return Vars{
+		CurrentVersion: rt.Current,
+		Releases:       *rt,
+	}, nil
+}
+
+// requiredList renders a list as a YAML flow sequence. An empty list is an
+// error, since dropping a field from a query makes it match far more bugs.
+func requiredList(in []string) (string, error) {
+	if len(in) == 0 {
+		return "", fmt.Errorf("required list is empty")
+	}
+	out, err := json.Marshal(in)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+var funcs = template.FuncMap{
+	"requiredList": requiredList,
+}
+
+type Rule struct {
+	api.BugAction
+
+	// Abstract rules are only used to be extended and are never run
+	Abstract bool `json:"abstract,omitempty"`
+	// Extends lists the rules whose query and update this rule starts from. Later entries win.
+	Extends []string `json:"extends,omitempty"`
+
+	file string
+}
+
+// RuleSet holds every rule found in a directory by name.
+type RuleSet map[string]*Rule
+
+func renderFile(path string, vars Vars) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Funcs(funcs).Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	out := &bytes.Buffer{}
+	if err := tmpl.Execute(out, vars); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// parseRule decodes a rendered rule, failing on any field which is not part of
+// a Rule or of the bugzilla query and update schemas.
+func parseRule(data []byte) (*Rule, error) {
+	j, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.DisallowUnknownFields()
+	rule := &Rule{}
+	if err := dec.Decode(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// Load renders and parses every .yaml file in dir.
+func Load(dir string, vars Vars) (RuleSet, error) {
+	rs := RuleSet{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".yaml" {
+			return nil
+		}
+		data, err := renderFile(path, vars)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		rule, err := parseRule(data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		rule.file = path
+		if rule.Name == "" {
+			return fmt.Errorf("%s: rule has no name", path)
+		}
+		if other, ok := rs[rule.Name]; ok {
+			return fmt.Errorf("%s: rule %q is already defined in %s", path, rule.Name, other.file)
+		}
+		rs[rule.Name] = rule
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func overrideStrings(dst *[]string, src []string) {
+	if len(src) != 0 {
+		*dst = append([]string{}, src...)
+	}
+}
+
+func overrideString(dst *string, src string) {
+	if src != "" {
+		*dst = src
+	}
+}
+
+// mergeQuery lays child on top of parent. Fields set in the child replace the
+// parent's, except advanced queries which are appended.
+func mergeQuery(parent, child bugzilla.Query) bugzilla.Query {
+	out := parent
+	out.Advanced = append([]bugzilla.AdvancedQuery{}, parent.Advanced...)
+	out.Advanced = append(out.Advanced, child.Advanced...)
+	overrideStrings(&out.Classification, child.Classification)
+	overrideStrings(&out.Product, child.Product)
+	overrideStrings(&out.Status, child.Status)
+	overrideStrings(&out.Priority, child.Priority)
+	overrideStrings(&out.Severity, child.Severity)
+	overrideStrings(&out.Keywords, child.Keywords)
+	overrideString(&out.KeywordsType, child.KeywordsType)
+	overrideStrings(&out.BugIDs, child.BugIDs)
+	overrideString(&out.BugIDsType, child.BugIDsType)
+	overrideStrings(&out.Component, child.Component)
+	overrideStrings(&out.TargetRelease, child.TargetRelease)
+	overrideStrings(&out.IncludeFields, child.IncludeFields)
+	overrideString(&out.Raw, child.Raw)
+	if len(out.Advanced) == 0 {
+		out.Advanced = nil
+	}
+	return out
+}
+
+func emptyUpdate(update bugzilla.BugUpdate) bool {
+	b, _ := json.Marshal(update)
+	return string(b) == "{}"
+}
+
+// resolve returns the rule with everything it extends merged in.
+func (rs RuleSet) resolve(name string, visiting map[string]bool) (*Rule, error) {
+	rule, ok := rs[name]
+	if !ok {
+		return nil, fmt.Errorf("rule %q not found", name)
+	}
+	if visiting[name] {
+		return nil, fmt.Errorf("rule %q extends itself", name)
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+
+	query := bugzilla.Query{}
+	update := bugzilla.BugUpdate{}
+	for _, parentName := range rule.Extends {
+		parent, err := rs.resolve(parentName, visiting)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		query = mergeQuery(query, parent.Query)
+		if !emptyUpdate(parent.Update) {
+			update = parent.Update
+		}
+	}
+
+	out := *rule
+	out.Query = mergeQuery(query, rule.Query)
+	out.Update = update
+	if !emptyUpdate(rule.Update) {
+		out.Update = rule.Update
+	}
+	return &out, nil
+}
+
+// Actions resolves every concrete rule into the BugAction bug-automation runs.
+func (rs RuleSet) Actions(vars Vars) ([]api.BugAction, error) {
+	names := make([]string, 0, len(rs))
+	for name := range rs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	actions := []api.BugAction{}
+	for _, name := range names {
+		rule, err := rs.resolve(name, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		if rule.Abstract {
+			continue
+		}
+		action := rule.BugAction
+		if len(vars.TestBugs) > 0 {
+			action.Query.BugIDs = vars.TestBugs
+			action.Query.BugIDsType = "anyexact"
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
@@ -0,0 +1,171 @@
+package rules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/eparis/bugzilla"
+)
+
+func writeRules(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestActionsInheritAndRender(t *testing.T) {
+	dir := writeRules(t, map[string]string{
+		"base.yaml": `
+name: base
+abstract: true
+query:
+  product:
+  - OpenShift Container Platform
+  advanced:
+  - field: component
+    op: equals
+    value: Documentation
+    negate: true
+update:
+  minor_update: true
+`,
+		"child.yaml": `
+name: child
+description: targets {{ .CurrentVersion }}
+extends:
+- base
+query:
+  target_release:
+  - {{ .CurrentVersion }}.0
+  advanced:
+  - field: dependson
+    op: isempty
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	vars := Vars{CurrentVersion: "4.7"}
+	rs, err := Load(dir, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := rs.Validate(); len(errs) != 0 {
+		t.Fatalf("unexpected validation errors: %v", errs)
+	}
+	actions, err := rs.Actions(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 {
+		t.Fatalf("expected only the concrete rule, got %d actions", len(actions))
+	}
+	action := actions[0]
+	if action.Description != "targets 4.7" {
+		t.Errorf("template not rendered: %q", action.Description)
+	}
+	expectedQuery := bugzilla.Query{
+		Product:       []string{"OpenShift Container Platform"},
+		TargetRelease: []string{"4.7.0"},
+		Advanced: []bugzilla.AdvancedQuery{
+			{Field: "component", Op: "equals", Value: "Documentation", Negate: true},
+			{Field: "dependson", Op: "isempty"},
+		},
+	}
+	if !reflect.DeepEqual(action.Query, expectedQuery) {
+		t.Errorf("expected query %#v, got %#v", expectedQuery, action.Query)
+	}
+	if !action.Update.MinorUpdate {
+		t.Errorf("expected update to be inherited from base")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		valid bool
+	}{
+		{
+			name:  "unknown field",
+			rule:  "name: r\ndescription: d\nquery:\n  prodcut: [OCP]\nupdate:\n  minor_update: true\n",
+			valid: false,
+		},
+		{
+			name:  "unlimited query",
+			rule:  "name: r\ndescription: d\nquery:\n  status: [NEW]\nupdate:\n  minor_update: true\n",
+			valid: false,
+		},
+		{
+			name:  "keywords without type",
+			rule:  "name: r\ndescription: d\nquery:\n  product: [OCP]\n  keywords: [Security]\nupdate:\n  minor_update: true\n",
+			valid: false,
+		},
+		{
+			name:  "bad advanced op",
+			rule:  "name: r\ndescription: d\nquery:\n  product: [OCP]\n  advanced:\n  - field: component\n    op: equal\n    value: x\nupdate:\n  minor_update: true\n",
+			valid: false,
+		},
+		{
+			name:  "missing parent",
+			rule:  "name: r\ndescription: d\nextends: [nope]\nquery:\n  product: [OCP]\nupdate:\n  minor_update: true\n",
+			valid: false,
+		},
+		{
+			name:  "bad schedule",
+			rule:  "name: r\ndescription: d\nschedule: ['every hour']\nquery:\n  product: [OCP]\nupdate:\n  minor_update: true\n",
+			valid: false,
+		},
+		{
+			name:  "valid",
+			rule:  "name: r\ndescription: d\nschedule: ['@hourly']\nquery:\n  product: [OCP]\nupdate:\n  minor_update: true\n",
+			valid: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeRules(t, map[string]string{"r.yaml": test.rule})
+			defer os.RemoveAll(dir)
+			rs, err := Load(dir, Vars{})
+			if err == nil {
+				errs := rs.Validate()
+				if len(errs) != 0 {
+					err = errs[0]
+				}
+			}
+			if (err == nil) != test.valid {
+				t.Errorf("expected valid=%v, got %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestRequiredList(t *testing.T) {
+	rule := "name: r\ndescription: d\nquery:\n  product: [OCP]\n  target_release: {{ requiredList .Releases.ShippedZeroTargets }}\nupdate:\n  minor_update: true\n"
+	dir := writeRules(t, map[string]string{"r.yaml": rule})
+	defer os.RemoveAll(dir)
+
+	vars := Vars{}
+	vars.Releases.ShippedZeroTargets = []string{"4.5.0", "4.6.0"}
+	rs, err := Load(dir, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"4.5.0", "4.6.0"}
+	if got := rs["r"].Query.TargetRelease; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	if _, err := Load(dir, Vars{}); err == nil {
+		t.Errorf("expected an empty list to fail to render")
+	}
+}
@@ -0,0 +1,107 @@
+package rules
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/eparis/bugzilla"
+	"github.com/robfig/cron"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var (
+	// https://bugzilla.readthedocs.io/en/latest/api/core/v1/bug.html#search-bugs
+	advancedOps = sets.NewString(
+		"equals", "notequals", "anyexact",
+		"substring", "casesubstring", "notsubstring",
+		"anywordssubstr", "allwordssubstr", "nowordssubstr",
+		"regexp", "notregexp",
+		"lessthan", "lessthaneq", "greaterthan", "greaterthaneq",
+		"anywords", "allwords", "nowords",
+		"changedbefore", "changedafter", "changedfrom", "changedto", "changedby",
+		"matches", "notmatches",
+		"isempty", "isnotempty",
+	)
+	// operators which do not take a value
+	valuelessOps = sets.NewString("isempty", "isnotempty")
+
+	keywordsTypes = sets.NewString("allwords", "anywords", "nowords", "anywordssubstr", "allwordssubstr", "nowordssubstr")
+	bugIDsTypes   = sets.NewString("anyexact", "nowords")
+)
+
+func validateQuery(query bugzilla.Query) []error {
+	errs := []error{}
+	if len(query.Keywords) != 0 && query.KeywordsType == "" {
+		errs = append(errs, fmt.Errorf("keywords set without keywords_type"))
+	}
+	if query.KeywordsType != "" && !keywordsTypes.Has(query.KeywordsType) {
+		errs = append(errs, fmt.Errorf("unknown keywords_type %q, must be one of %v", query.KeywordsType, keywordsTypes.List()))
+	}
+	if len(query.BugIDs) != 0 && query.BugIDsType == "" {
+		errs = append(errs, fmt.Errorf("bug_ids set without bug_ids_type"))
+	}
+	if query.BugIDsType != "" && !bugIDsTypes.Has(query.BugIDsType) {
+		errs = append(errs, fmt.Errorf("unknown bug_ids_type %q, must be one of %v", query.BugIDsType, bugIDsTypes.List()))
+	}
+	for i, adv := range query.Advanced {
+		if adv.Field == "" {
+			errs = append(errs, fmt.Errorf("advanced[%d] has no field", i))
+		}
+		if !advancedOps.Has(adv.Op) {
+			errs = append(errs, fmt.Errorf("advanced[%d] has unknown op %q", i, adv.Op))
+		} else if valuelessOps.Has(adv.Op) && adv.Value != "" {
+			errs = append(errs, fmt.Errorf("advanced[%d] op %q does not take a value", i, adv.Op))
+		} else if !valuelessOps.Has(adv.Op) && adv.Value == "" {
+			errs = append(errs, fmt.Errorf("advanced[%d] op %q requires a value", i, adv.Op))
+		}
+	}
+	if _, err := url.ParseQuery(query.Raw); err != nil {
+		errs = append(errs, fmt.Errorf("unable to parse raw query %q: %v", query.Raw, err))
+	}
+	return errs
+}
+
+// Validate checks every rule can be resolved and that every concrete rule
+// produces a query bugzilla will understand and an update which does something.
+// Queries must be limited to a product, component or set of bugs so a mistake
+// can not match every bug in bugzilla.
+func (rs RuleSet) Validate() []error {
+	names := make([]string, 0, len(rs))
+	for name := range rs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := []error{}
+	for _, name := range names {
+		rule, err := rs.resolve(name, map[string]bool{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", rs[name].file, err))
+			continue
+		}
+		if rule.Abstract {
+			continue
+		}
+		ruleErrs := validateQuery(rule.Query)
+		query := rule.Query
+		if len(query.Product) == 0 && len(query.Component) == 0 && len(query.BugIDs) == 0 {
+			ruleErrs = append(ruleErrs, fmt.Errorf("query must be limited by product, component or bug_ids"))
+		}
+		if emptyUpdate(rule.Update) {
+			ruleErrs = append(ruleErrs, fmt.Errorf("update is empty"))
+		}
+		if rule.Description == "" {
+			ruleErrs = append(ruleErrs, fmt.Errorf("description is empty"))
+		}
+		for _, schedule := range rule.Schedule {
+			if _, err := cron.ParseStandard(schedule); err != nil {
+				ruleErrs = append(ruleErrs, fmt.Errorf("invalid schedule %q: %v", schedule, err))
+			}
+		}
+		for _, err := range ruleErrs {
+			errs = append(errs, fmt.Errorf("%s: %s: %v", rule.file, name, err))
+		}
+	}
+	return errs
+}
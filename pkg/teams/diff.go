@@ -0,0 +1,131 @@
+package teams
+
+import (
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// StringChange is a field which changed from one value to another
+type StringChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// IntChange is a number which changed from one value to another
+type IntChange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// TeamDiff is how a team changed between two versions of the org data. Only
+// the fields which changed are set.
+type TeamDiff struct {
+	Team    string `json:"team"`
+	Added   bool   `json:"added,omitempty"`
+	Removed bool   `json:"removed,omitempty"`
+
+	ComponentsAdded      []string `json:"componentsAdded,omitempty"`
+	ComponentsRemoved    []string `json:"componentsRemoved,omitempty"`
+	SubcomponentsAdded   []string `json:"subcomponentsAdded,omitempty"`
+	SubcomponentsRemoved []string `json:"subcomponentsRemoved,omitempty"`
+	ManagersAdded        []string `json:"managersAdded,omitempty"`
+	ManagersRemoved      []string `json:"managersRemoved,omitempty"`
+
+	SlackChan   *StringChange `json:"slackChan,omitempty"`
+	Lead        *StringChange `json:"lead,omitempty"`
+	Group       *StringChange `json:"group,omitempty"`
+	MemberCount *IntChange    `json:"memberCount,omitempty"`
+
+	// SLOsChanged are the names of the SLO overrides which were added,
+	// removed or changed
+	SLOsChanged []string `json:"slosChanged,omitempty"`
+}
+
+func (d TeamDiff) empty() bool {
+	return reflect.DeepEqual(d, TeamDiff{Team: d.Team})
+}
+
+// setDiff returns what is in to but not from, and what is in from but not to,
+// both sorted. Either is nil if empty.
+func setDiff(from, to sets.String) (added, removed []string) {
+	if a := to.Difference(from); a.Len() > 0 {
+		added = a.List()
+	}
+	if r := from.Difference(to); r.Len() > 0 {
+		removed = r.List()
+	}
+	return added, removed
+}
+
+func subcomponentSet(team TeamInfo) sets.String {
+	out := sets.NewString()
+	for component, subcomponents := range team.Subcomponents {
+		for _, subcomponent := range subcomponents {
+			out.Insert(component + "/" + subcomponent)
+		}
+	}
+	return out
+}
+
+func stringChange(from, to string) *StringChange {
+	if from == to {
+		return nil
+	}
+	return &StringChange{From: from, To: to}
+}
+
+// diffTeam compares two versions of a team, either may be the zero TeamInfo
+// if the team did not exist.
+func diffTeam(name string, from, to TeamInfo) TeamDiff {
+	d := TeamDiff{Team: name}
+	d.ComponentsAdded, d.ComponentsRemoved = setDiff(sets.NewString(from.Components...), sets.NewString(to.Components...))
+	d.SubcomponentsAdded, d.SubcomponentsRemoved = setDiff(subcomponentSet(from), subcomponentSet(to))
+	d.ManagersAdded, d.ManagersRemoved = setDiff(sets.NewString(from.Managers...), sets.NewString(to.Managers...))
+	d.SlackChan = stringChange(from.SlackChan, to.SlackChan)
+	d.Lead = stringChange(from.Lead, to.Lead)
+	d.Group = stringChange(from.Group, to.Group)
+	if from.MemberCount != to.MemberCount {
+		d.MemberCount = &IntChange{From: from.MemberCount, To: to.MemberCount}
+	}
+	slos := sets.NewString()
+	for slo, data := range from.SLO {
+		if toData, ok := to.SLO[slo]; !ok || toData != data {
+			slos.Insert(slo)
+		}
+	}
+	for slo := range to.SLO {
+		if _, ok := from.SLO[slo]; !ok {
+			slos.Insert(slo)
+		}
+	}
+	if slos.Len() > 0 {
+		d.SLOsChanged = slos.List()
+	}
+	return d
+}
+
+// Diff returns how every team which changed between from and to changed,
+// sorted by team name.
+func Diff(from, to *OrgData) []TeamDiff {
+	names := sets.NewString()
+	for name := range from.Teams {
+		names.Insert(name)
+	}
+	for name := range to.Teams {
+		names.Insert(name)
+	}
+
+	out := []TeamDiff{}
+	for _, name := range names.List() {
+		fromTeam, inFrom := from.Teams[name]
+		toTeam, inTo := to.Teams[name]
+		d := diffTeam(name, fromTeam, toTeam)
+		d.Added = !inFrom
+		d.Removed = !inTo
+		if !d.empty() {
+			out = append(out, d)
+		}
+	}
+	return out
+}
@@ -0,0 +1,123 @@
+package teams
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var (
+	// reconcileBackoff is how long Reconciler waits to retry after failing to
+	// load the org data. It never waits longer than the usual resync.
+	reconcileBackoff = wait.Backoff{
+		Duration: 15 * time.Second,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    10,
+		Cap:      reconcilePeriod,
+	}
+)
+
+// loadStatus is when the org data was last loaded and whether loading it
+// again has been failing since. It is shared by every version of the org data
+// Reconcile loads.
+type loadStatus struct {
+	lock        sync.Mutex
+	maxAge      time.Duration
+	loadedAt    time.Time
+	lastAttempt time.Time
+	failures    int
+	lastError   error
+}
+
+func newLoadStatus(maxAge time.Duration, loadedAt time.Time) *loadStatus {
+	lastSuccess.Set(float64(loadedAt.Unix()))
+	consecutiveFailures.Set(0)
+	return &loadStatus{maxAge: maxAge, loadedAt: loadedAt, lastAttempt: loadedAt}
+}
+
+func (s *loadStatus) loaded(at time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.loadedAt = at
+	s.lastAttempt = at
+	s.failures = 0
+	s.lastError = nil
+	lastSuccess.Set(float64(at.Unix()))
+	consecutiveFailures.Set(0)
+}
+
+func (s *loadStatus) failed(at time.Time, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lastAttempt = at
+	s.failures++
+	s.lastError = err
+	consecutiveFailures.Set(float64(s.failures))
+}
+
+// Health is how fresh the org data is.
+type Health struct {
+	// Healthy is false once the org data is older than --org-data-max-age
+	Healthy     bool      `json:"healthy"`
+	LoadedAt    time.Time `json:"loadedAt"`
+	Age         string    `json:"age"`
+	MaxAge      string    `json:"maxAge"`
+	LastAttempt time.Time `json:"lastAttempt"`
+	// ConsecutiveFailures is how many times loading it has failed since it
+	// was last loaded
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	LastError           string `json:"lastError,omitempty"`
+}
+
+// Health returns how fresh the org data is at now.
+func (orgData *OrgData) Health(now time.Time) Health {
+	s := orgData.status
+	if s == nil {
+		return Health{}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	age := now.Sub(s.loadedAt)
+	h := Health{
+		Healthy:             age <= s.maxAge,
+		LoadedAt:            s.loadedAt,
+		Age:                 age.Round(time.Second).String(),
+		MaxAge:              s.maxAge.String(),
+		LastAttempt:         s.lastAttempt,
+		ConsecutiveFailures: s.failures,
+	}
+	if s.lastError != nil {
+		h.LastError = s.lastError.Error()
+	}
+	return h
+}
+
+// Loaded is true once the org data was loaded.
+func (orgData *OrgData) Loaded() bool {
+	s := orgData.status
+	if s == nil {
+		return false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return !s.loadedAt.IsZero()
+}
+
+// HealthHandler serves Health, with a 503 if the org data is stale.
+func (orgData *OrgData) HealthHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h := orgData.Health(time.Now())
+		w.Header().Set("Content-Type", "application/json")
+		if !h.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(h); err != nil {
+			fmt.Printf("Unable to encode: %v: %v\n", h, err)
+		}
+	}
+}
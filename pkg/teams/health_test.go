@@ -0,0 +1,107 @@
+package teams
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestReconcileKeepsLastGoodData(t *testing.T) {
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(OrgData{Teams: map[string]TeamInfo{"Node": {Name: "Node", Components: []string{"Node"}}}})
+	}))
+	defer server.Close()
+
+	cmd := &cobra.Command{}
+	AddFlags(cmd)
+	cmd.Flags().Set(orgDataURLFlagName, server.URL)
+	orgData, err := GetOrgData(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fail = true
+	if err := orgData.Reconcile(); err == nil {
+		t.Fatalf("expected reconcile to fail")
+	}
+	if _, ok := orgData.Teams["Node"]; !ok || len(orgData.Teams) != 1 {
+		t.Errorf("expected the last good org data, got %v", orgData.Teams)
+	}
+	h := orgData.Health(time.Now())
+	if !h.Healthy || h.ConsecutiveFailures != 1 || h.LastError == "" {
+		t.Errorf("expected a healthy single failure, got %+v", h)
+	}
+	if h := orgData.Health(time.Now().Add(2 * maxAgeFlagDefVal)); h.Healthy {
+		t.Errorf("expected org data older than the max age to be stale, got %+v", h)
+	}
+
+	fail = false
+	if err := orgData.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	if h := orgData.Health(time.Now()); h.ConsecutiveFailures != 0 || h.LastError != "" {
+		t.Errorf("expected a successful reconcile to reset failures, got %+v", h)
+	}
+}
+
+func TestReconcileRunsOneAtATime(t *testing.T) {
+	var inFlight, maxInFlight, loads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		if n > atomic.LoadInt32(&maxInFlight) {
+			atomic.StoreInt32(&maxInFlight, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+		// every load is a different team so the org data keeps changing
+		name := fmt.Sprintf("team-%d", atomic.AddInt32(&loads, 1))
+		json.NewEncoder(w).Encode(OrgData{Teams: map[string]TeamInfo{name: {Name: name}}})
+	}))
+	defer server.Close()
+
+	cmd := &cobra.Command{}
+	AddFlags(cmd)
+	cmd.Flags().Set(orgDataURLFlagName, server.URL)
+	orgData, err := GetOrgData(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := orgData.Reconcile(); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				current := orgData.Current()
+				for name, team := range current.Teams {
+					if name != team.Name {
+						t.Errorf("expected a consistent org data, got team %s named %s", name, team.Name)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if maxInFlight != 1 {
+		t.Errorf("expected one reconcile at a time, got %d at once", maxInFlight)
+	}
+}
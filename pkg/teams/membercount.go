@@ -0,0 +1,335 @@
+package teams
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/kr/pretty"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/sheets/v4"
+)
+
+// The member count sources --member-count-source can choose
+const (
+	// SheetSource reads a Google sheet as a service account
+	SheetSource = "sheet"
+	// SheetOAuthSource reads a Google sheet as a user, it needs a browser the
+	// first time to create token.json
+	SheetOAuthSource = "sheet-oauth"
+	// FileSource reads a CSV or YAML file
+	FileSource = "file"
+	// OrgDataSource uses the memberCount of each team in the org data itself
+	OrgDataSource = "org-data"
+)
+
+const (
+	memberCountSourceFlagName   = "member-count-source"
+	memberCountSourceFlagDefVal = SheetOAuthSource
+
+	gsheetKeyFlagName   = "google-sheet"
+	gsheetKeyFlagDefVal = "./"
+
+	sheetCredentialsFlagName   = "member-count-sheet-credentials"
+	sheetCredentialsFlagDefVal = ""
+
+	sheetIDFlagName   = "member-count-sheet-id"
+	sheetIDFlagDefVal = "1M4C41fX2J1nBXhqPdtwd8UP4RAx98NA4ByIUv-0Z0Ds"
+
+	sheetRangeFlagName   = "member-count-sheet-range"
+	sheetRangeFlagDefVal = `'OCP Team Structure'!B:C`
+
+	memberCountFileFlagName   = "member-count-file"
+	memberCountFileFlagDefVal = ""
+
+	sheetsScope = "https://www.googleapis.com/auth/spreadsheets.readonly"
+)
+
+// MemberCountSource says how many people are on each team.
+type MemberCountSource interface {
+	// MemberCounts returns the size of every team it knows by team name. A
+	// nil map leaves the member counts in the org data alone.
+	MemberCounts(ctx context.Context) (map[string]int, error)
+}
+
+// addCount parses a team and size and adds it to counts. Rows which are not a
+// team and a number, like headers, are skipped.
+func addCount(counts map[string]int, team, size string) {
+	team = strings.TrimSpace(team)
+	f, err := strconv.ParseFloat(strings.TrimSpace(size), 32)
+	if team == "" || err != nil {
+		return
+	}
+	counts[team] = int(f)
+}
+
+// sheetMemberCounts reads the team name and size from the two columns of a
+// range in a Google sheet.
+type sheetMemberCounts struct {
+	client        func(ctx context.Context) (*http.Client, error)
+	spreadsheetID string
+	readRange     string
+}
+
+func (s sheetMemberCounts) MemberCounts(ctx context.Context) (map[string]int, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	service, err := sheets.New(client)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := service.Spreadsheets.Values.Get(s.spreadsheetID, s.readRange).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Values) == 0 {
+		return nil, fmt.Errorf("No data found in google sheet %s range %s", s.spreadsheetID, s.readRange)
+	}
+
+	counts := map[string]int{}
+	for _, row := range resp.Values {
+		if len(row) != 2 {
+			continue
+		}
+		addCount(counts, fmt.Sprint(row[0]), fmt.Sprint(row[1]))
+	}
+	return counts, nil
+}
+
+// serviceAccountClient authenticates with the service account key at path.
+func serviceAccountClient(path string) func(ctx context.Context) (*http.Client, error) {
+	return func(ctx context.Context) (*http.Client, error) {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read service account key: %v", err)
+		}
+		config, err := google.JWTConfigFromJSON(b, sheetsScope)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse service account key: %v", err)
+		}
+		return config.Client(ctx), nil
+	}
+}
+
+// oauthClient authenticates with the client secret config.json and the user
+// token.json in dir.
+func oauthClient(dir string) func(ctx context.Context) (*http.Client, error) {
+	return func(ctx context.Context) (*http.Client, error) {
+		filename := filepath.Join(dir, "config.json")
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read client secret file: %v", err)
+		}
+
+		// If modifying these scopes, delete your previously saved token.json.
+		config, err := google.ConfigFromJSON(b, sheetsScope)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse client secret file to config: %v", err)
+		}
+		return getClient(dir, config)
+	}
+}
+
+// Retrieve a token, saves the token, then returns the generated client.
+func getClient(dir string, config *oauth2.Config) (*http.Client, error) {
+	// The file token.json stores the user's access and refresh tokens, and is
+	// created automatically when the authorization flow completes for the first
+	// time.
+	tokFile := filepath.Join(dir, "token.json")
+	tok, err := tokenFromFile(tokFile)
+	if err != nil {
+		tok, err = getTokenFromWeb(config)
+		if err != nil {
+			return nil, err
+		}
+		if err := saveToken(tokFile, tok); err != nil {
+			return nil, err
+		}
+	}
+	return config.Client(context.Background(), tok), nil
+}
+
+// Request a token from the web, then returns the retrieved token.
+func getTokenFromWeb(config *oauth2.Config) (*oauth2.Token, error) {
+	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+	fmt.Printf("Go to the following link in your browser then type the "+
+		"authorization code: \n%v\n", authURL)
+
+	var authCode string
+	if _, err := fmt.Scan(&authCode); err != nil {
+		return nil, fmt.Errorf("Unable to read authorization code: %v", err)
+	}
+
+	tok, err := config.Exchange(context.TODO(), authCode)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve token from web: %v", err)
+	}
+	return tok, nil
+}
+
+// Retrieves a token from a local file.
+func tokenFromFile(file string) (*oauth2.Token, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tok := &oauth2.Token{}
+	err = json.NewDecoder(f).Decode(tok)
+	return tok, err
+}
+
+// Saves a token to a file path.
+func saveToken(path string, token *oauth2.Token) error {
+	fmt.Printf("Saving credential file to: %s\n", path)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to cache oauth token: %v", err)
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(token)
+}
+
+// fileMemberCounts reads a CSV file of team,size rows, or a YAML file mapping
+// each team to its size.
+type fileMemberCounts struct {
+	path string
+}
+
+func (f fileMemberCounts) MemberCounts(ctx context.Context) (map[string]int, error) {
+	b, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ToLower(filepath.Ext(f.path)) != ".csv" {
+		counts := map[string]int{}
+		if err := yaml.Unmarshal(b, &counts); err != nil {
+			return nil, fmt.Errorf("unable to parse member counts in %s: %v", f.path, err)
+		}
+		return counts, nil
+	}
+
+	r := csv.NewReader(strings.NewReader(string(b)))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse member counts in %s: %v", f.path, err)
+	}
+	counts := map[string]int{}
+	for _, row := range rows {
+		if len(row) != 2 {
+			continue
+		}
+		addCount(counts, row[0], row[1])
+	}
+	return counts, nil
+}
+
+// orgDataMemberCounts leaves the memberCount already in the org data.
+type orgDataMemberCounts struct{}
+
+func (orgDataMemberCounts) MemberCounts(ctx context.Context) (map[string]int, error) {
+	return nil, nil
+}
+
+// GetMemberCountSource returns the source chosen by --member-count-source.
+func GetMemberCountSource(cmd *cobra.Command) (MemberCountSource, error) {
+	source, err := cmd.Flags().GetString(memberCountSourceFlagName)
+	if err != nil {
+		return nil, err
+	}
+	sheetID, err := cmd.Flags().GetString(sheetIDFlagName)
+	if err != nil {
+		return nil, err
+	}
+	readRange, err := cmd.Flags().GetString(sheetRangeFlagName)
+	if err != nil {
+		return nil, err
+	}
+
+	switch source {
+	case SheetSource:
+		credentials, err := cmd.Flags().GetString(sheetCredentialsFlagName)
+		if err != nil {
+			return nil, err
+		}
+		if credentials == "" {
+			return nil, fmt.Errorf("--%s=%s needs --%s", memberCountSourceFlagName, SheetSource, sheetCredentialsFlagName)
+		}
+		return sheetMemberCounts{client: serviceAccountClient(credentials), spreadsheetID: sheetID, readRange: readRange}, nil
+	case SheetOAuthSource:
+		dir, err := cmd.Flags().GetString(gsheetKeyFlagName)
+		if err != nil {
+			return nil, err
+		}
+		if dir == "" {
+			return orgDataMemberCounts{}, nil
+		}
+		return sheetMemberCounts{client: oauthClient(dir), spreadsheetID: sheetID, readRange: readRange}, nil
+	case FileSource:
+		path, err := cmd.Flags().GetString(memberCountFileFlagName)
+		if err != nil {
+			return nil, err
+		}
+		if path == "" {
+			return nil, fmt.Errorf("--%s=%s needs --%s", memberCountSourceFlagName, FileSource, memberCountFileFlagName)
+		}
+		return fileMemberCounts{path: path}, nil
+	case OrgDataSource:
+		return orgDataMemberCounts{}, nil
+	}
+	return nil, fmt.Errorf("unknown --%s %q, must be one of %s, %s, %s or %s", memberCountSourceFlagName, source, SheetSource, SheetOAuthSource, FileSource, OrgDataSource)
+}
+
+// setMemberCounts sets the member count of every team in counts and records
+// the teams in counts which are not in the org data.
+func (orgData *OrgData) setMemberCounts(counts map[string]int) {
+	orgData.sheetOnlyTeams = nil
+	for team, count := range counts {
+		if teamInfo, ok := orgData.Teams[team]; ok {
+			teamInfo.MemberCount = count
+			orgData.Teams[team] = teamInfo
+		} else {
+			pretty.Printf("Team %q: Found in the member counts but not found in shiftzilla team config.\n", team)
+			orgData.sheetOnlyTeams = append(orgData.sheetOnlyTeams, team)
+		}
+	}
+	sort.Strings(orgData.sheetOnlyTeams)
+}
+
+func applyMemberCounts(cmd *cobra.Command, orgData *OrgData) error {
+	source, err := GetMemberCountSource(cmd)
+	if err != nil {
+		return err
+	}
+	counts, err := source.MemberCounts(context.Background())
+	if err != nil {
+		return err
+	}
+	orgData.setMemberCounts(counts)
+	_, fromOrgData := source.(orgDataMemberCounts)
+	orgData.sheetChecked = !fromOrgData
+	return nil
+}
+
+func addMemberCountFlags(cmd *cobra.Command) {
+	cmd.Flags().String(memberCountSourceFlagName, memberCountSourceFlagDefVal, fmt.Sprintf("Where team member counts come from: %s (service account), %s (user oauth token), %s or %s", SheetSource, SheetOAuthSource, FileSource, OrgDataSource))
+	cmd.Flags().String(gsheetKeyFlagName, gsheetKeyFlagDefVal, "Path to the directory containing google sheets oauth keys for --member-count-source=sheet-oauth, empty to not load team sizes")
+	cmd.Flags().String(sheetCredentialsFlagName, sheetCredentialsFlagDefVal, "Path to a google service account key for --member-count-source=sheet")
+	cmd.Flags().String(sheetIDFlagName, sheetIDFlagDefVal, "ID of the google sheet with team member counts")
+	cmd.Flags().String(sheetRangeFlagName, sheetRangeFlagDefVal, "Range of the google sheet with a team name and member count column")
+	cmd.Flags().String(memberCountFileFlagName, memberCountFileFlagDefVal, "Path to a CSV file of team,count rows or a YAML file of team: count for --member-count-source=file")
+}
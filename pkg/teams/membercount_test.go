@@ -0,0 +1,106 @@
+package teams
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestFileMemberCounts(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"counts.csv", "Team,Size\nNode,7\nStorage, 4.0\n,3\n"},
+		{"counts.yaml", "Node: 7\nStorage: 4\n"},
+	}
+	expected := map[string]int{"Node": 7, "Storage": 4}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name)
+		if err := ioutil.WriteFile(path, []byte(test.contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+		counts, err := fileMemberCounts{path: path}.MemberCounts(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(counts, expected) {
+			t.Errorf("%s: expected %v got %v", test.name, expected, counts)
+		}
+	}
+}
+
+func TestGetMemberCountSource(t *testing.T) {
+	tests := []struct {
+		flags    map[string]string
+		expected MemberCountSource
+		err      bool
+	}{
+		{flags: map[string]string{gsheetKeyFlagName: ""}, expected: orgDataMemberCounts{}},
+		{flags: map[string]string{memberCountSourceFlagName: OrgDataSource}, expected: orgDataMemberCounts{}},
+		{flags: map[string]string{memberCountSourceFlagName: FileSource, memberCountFileFlagName: "counts.csv"}, expected: fileMemberCounts{path: "counts.csv"}},
+		{flags: map[string]string{memberCountSourceFlagName: FileSource}, err: true},
+		{flags: map[string]string{memberCountSourceFlagName: SheetSource}, err: true},
+		{flags: map[string]string{memberCountSourceFlagName: "ldap"}, err: true},
+	}
+	for _, test := range tests {
+		cmd := &cobra.Command{}
+		AddFlags(cmd)
+		for flag, value := range test.flags {
+			cmd.Flags().Set(flag, value)
+		}
+		source, err := GetMemberCountSource(cmd)
+		if test.err {
+			if err == nil {
+				t.Errorf("%v: expected an error, got %#v", test.flags, source)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test.flags, err)
+			continue
+		}
+		if !reflect.DeepEqual(source, test.expected) {
+			t.Errorf("%v: expected %#v got %#v", test.flags, test.expected, source)
+		}
+	}
+}
+
+func TestSetMemberCounts(t *testing.T) {
+	orgData := &OrgData{Teams: map[string]TeamInfo{
+		"Node":    {Name: "Node", MemberCount: 3},
+		"Storage": {Name: "Storage", MemberCount: 5},
+	}}
+	orgData.setMemberCounts(map[string]int{"Node": 7, "Sheet": 2})
+	if orgData.Teams["Node"].MemberCount != 7 || orgData.Teams["Storage"].MemberCount != 5 {
+		t.Errorf("expected Node to be 7 and Storage to keep 5, got %v", orgData.Teams)
+	}
+	if !reflect.DeepEqual(orgData.sheetOnlyTeams, []string{"Sheet"}) {
+		t.Errorf("expected Sheet to be only in the member counts, got %v", orgData.sheetOnlyTeams)
+	}
+}
+
+func TestApplyMemberCountsChecksSheet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counts.yaml")
+	if err := ioutil.WriteFile(path, []byte("Node: 7\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for source, checked := range map[string]bool{OrgDataSource: false, FileSource: true} {
+		cmd := &cobra.Command{}
+		AddFlags(cmd)
+		cmd.Flags().Set(memberCountSourceFlagName, source)
+		cmd.Flags().Set(memberCountFileFlagName, path)
+		orgData := &OrgData{Teams: map[string]TeamInfo{"Node": {Name: "Node"}}}
+		if err := applyMemberCounts(cmd, orgData); err != nil {
+			t.Fatal(err)
+		}
+		if orgData.SheetChecked() != checked {
+			t.Errorf("%s: expected SheetChecked to be %v", source, checked)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package teams
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	reconcileDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "orgdata_reconcile_duration_seconds",
+		Help:    "How long loading the org data took",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 10),
+	})
+	reconcileErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "orgdata_reconcile_errors_total",
+		Help: "Failed attempts to load the org data",
+	})
+	lastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "orgdata_last_reconcile_success_timestamp_seconds",
+		Help: "When the org data in use was loaded",
+	})
+	consecutiveFailures = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "orgdata_reconcile_consecutive_failures",
+		Help: "Failed attempts to load the org data since it was last loaded",
+	})
+)
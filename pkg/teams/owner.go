@@ -0,0 +1,128 @@
+package teams
+
+import (
+	"fmt"
+
+	"github.com/eparis/bugzilla"
+)
+
+// OwnerRule is why a team owns a component and subcomponent. Lower rules win.
+type OwnerRule int
+
+const (
+	// SubcomponentRule is a team listing the subcomponent
+	SubcomponentRule OwnerRule = iota
+	// ComponentRule is a team owning the component without listing
+	// subcomponents, so it owns all of them
+	ComponentRule
+	// DefaultRule is a team listing !!DEFAULT!! as the only subcomponent, it
+	// owns the subcomponents no other team lists
+	DefaultRule
+
+	noRule
+)
+
+func (r OwnerRule) String() string {
+	switch r {
+	case SubcomponentRule:
+		return "subcomponent"
+	case ComponentRule:
+		return "component"
+	case DefaultRule:
+		return "default"
+	}
+	return "none"
+}
+
+// OwnerMatch is a team matching one component and subcomponent of a bug
+type OwnerMatch struct {
+	Team         string
+	Component    string
+	Subcomponent string
+	Rule         OwnerRule
+}
+
+func (m OwnerMatch) String() string {
+	subcomponent := m.Subcomponent
+	if subcomponent == "" {
+		subcomponent = "<none>"
+	}
+	return fmt.Sprintf("%s/%s -> %s (%s)", m.Component, subcomponent, m.Team, m.Rule)
+}
+
+// Ownership is how the team of a bug was chosen.
+type Ownership struct {
+	// Team is the owner, "unknown" if no team matched
+	Team string
+	// Matches is every team matching any component and subcomponent of the
+	// bug, in the order they were considered
+	Matches []OwnerMatch
+	// Winner is the match Team came from, nil if no team matched
+	Winner *OwnerMatch
+	// Conflicts are the other teams matching with the same rule as Winner
+	Conflicts []string
+}
+
+// ownerRule returns why team owns the component and subcomponent, noRule if
+// it does not.
+func ownerRule(team TeamInfo, component, subcomponent string) OwnerRule {
+	yes, isDef := isForTeam(team, component, subcomponent)
+	switch {
+	case isDef:
+		return DefaultRule
+	case !yes:
+		return noRule
+	}
+	if _, ok := team.Subcomponents[component]; ok {
+		return SubcomponentRule
+	}
+	return ComponentRule
+}
+
+// ResolveOwner works out which team owns a bug. Every component of the bug,
+// and every subcomponent of those, is matched against every team. The match
+// with the lowest OwnerRule wins, ties go to the component and subcomponent
+// listed first on the bug and then to the team whose name sorts first. Any
+// other team matching with the winning rule is a conflict.
+func (orgData OrgData) ResolveOwner(bug *bugzilla.Bug) Ownership {
+	out := Ownership{Team: "unknown"}
+	teamNames := orgData.GetTeamNames()
+	for _, component := range bug.Component {
+		subcomponents := bug.SubComponent[component]
+		if len(subcomponents) == 0 {
+			subcomponents = []string{""}
+		}
+		for _, subcomponent := range subcomponents {
+			for _, name := range teamNames {
+				rule := ownerRule(orgData.Teams[name], component, subcomponent)
+				if rule == noRule {
+					continue
+				}
+				out.Matches = append(out.Matches, OwnerMatch{
+					Team:         name,
+					Component:    component,
+					Subcomponent: subcomponent,
+					Rule:         rule,
+				})
+			}
+		}
+	}
+
+	for i := range out.Matches {
+		if out.Winner == nil || out.Matches[i].Rule < out.Winner.Rule {
+			out.Winner = &out.Matches[i]
+		}
+	}
+	if out.Winner == nil {
+		return out
+	}
+	out.Team = out.Winner.Team
+	seen := map[string]bool{out.Team: true}
+	for _, match := range out.Matches {
+		if match.Rule == out.Winner.Rule && !seen[match.Team] {
+			seen[match.Team] = true
+			out.Conflicts = append(out.Conflicts, match.Team)
+		}
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package teams
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eparis/bugzilla"
+)
+
+func TestResolveOwner(t *testing.T) {
+	orgData := OrgData{Teams: map[string]TeamInfo{
+		"Auth":       {Name: "Auth", Components: []string{"apiserver-auth"}},
+		"Monitoring": {Name: "Monitoring", Components: []string{"Monitoring"}},
+		"Logging":    {Name: "Logging", Components: []string{"Logging"}},
+		"Metering":   {Name: "Metering", Components: []string{"Logging"}},
+		"Node":       {Name: "Node", Components: []string{"Node"}, Subcomponents: map[string][]string{"Node": {"Kubelet", "CRI-O"}}},
+		"NodeDef":    {Name: "NodeDef", Components: []string{"Node"}, Subcomponents: map[string][]string{"Node": {defaultForSubcomponentsTag}}},
+		"Storage":    {Name: "Storage", Components: []string{"Storage"}, Subcomponents: map[string][]string{"Storage": {"Kubernetes"}}},
+		// teams are named by their key, whatever their name says
+		"Console": {Name: "Web Console", Components: []string{"Management Console"}},
+	}}
+	tests := []struct {
+		name      string
+		bug       bugzilla.Bug
+		team      string
+		rule      OwnerRule
+		conflicts []string
+	}{
+		{
+			name: "component",
+			bug:  bugzilla.Bug{Component: []string{"Monitoring"}},
+			team: "Monitoring",
+			rule: ComponentRule,
+		},
+		{
+			name: "subcomponent beats default",
+			bug:  bugzilla.Bug{Component: []string{"Node"}, SubComponent: map[string][]string{"Node": {"CRI-O"}}},
+			team: "Node",
+			rule: SubcomponentRule,
+		},
+		{
+			name: "default",
+			bug:  bugzilla.Bug{Component: []string{"Node"}, SubComponent: map[string][]string{"Node": {"Other"}}},
+			team: "NodeDef",
+			rule: DefaultRule,
+		},
+		{
+			name: "later subcomponent is explicit",
+			bug:  bugzilla.Bug{Component: []string{"Node"}, SubComponent: map[string][]string{"Node": {"Other", "Kubelet"}}},
+			team: "Node",
+			rule: SubcomponentRule,
+		},
+		{
+			name: "later component is more specific",
+			bug:  bugzilla.Bug{Component: []string{"Node", "Storage"}, SubComponent: map[string][]string{"Node": {"Other"}, "Storage": {"Kubernetes"}}},
+			team: "Storage",
+			rule: SubcomponentRule,
+		},
+		{
+			name:      "first component wins a tie",
+			bug:       bugzilla.Bug{Component: []string{"Monitoring", "apiserver-auth"}},
+			team:      "Monitoring",
+			rule:      ComponentRule,
+			conflicts: []string{"Auth"},
+		},
+		{
+			name:      "two teams own a component",
+			bug:       bugzilla.Bug{Component: []string{"Logging"}},
+			team:      "Logging",
+			rule:      ComponentRule,
+			conflicts: []string{"Metering"},
+		},
+		{
+			name: "team key",
+			bug:  bugzilla.Bug{Component: []string{"Management Console"}},
+			team: "Console",
+			rule: ComponentRule,
+		},
+		{
+			name: "unowned",
+			bug:  bugzilla.Bug{Component: []string{"Documentation"}},
+			team: "unknown",
+			rule: noRule,
+		},
+		{
+			name: "no component",
+			team: "unknown",
+			rule: noRule,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// run a few times, map order must not matter
+			for i := 0; i < 10; i++ {
+				owner := orgData.ResolveOwner(&test.bug)
+				rule := noRule
+				if owner.Winner != nil {
+					rule = owner.Winner.Rule
+				}
+				if owner.Team != test.team || rule != test.rule || !reflect.DeepEqual(owner.Conflicts, test.conflicts) {
+					t.Fatalf("expected %s by %s with conflicts %v, got %s by %s with conflicts %v", test.team, test.rule, test.conflicts, owner.Team, rule, owner.Conflicts)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package teams
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	milestoneDateFormat = "2006-01-02"
+)
+
+// ReleaseTargets describes where each release is in its lifecycle so tools do
+// not need to hardcode release lists which go stale every release.
+type ReleaseTargets struct {
+	// Current is the x.y release currently in development, eg "4.7"
+	Current string `json:"current"`
+	// CurrentTargets are the non z-stream target releases of Current, eg ["4.7.0"]
+	CurrentTargets []string `json:"currentTargets"`
+	// Shipped are the x.y releases which have GA'd, oldest first
+	Shipped []string `json:"shipped"`
+	// ShippedZeroTargets are the x.y.0 targets of Shipped releases and of
+	// shippedZeroFloor, eg ["4.1.0", ..., "4.5.0", "4.6.0"]
+	ShippedZeroTargets []string `json:"shippedZeroTargets"`
+	// ZStreamTargets are every x.y.z target release in the org data, eg ["4.5.z", "4.6.z"]
+	ZStreamTargets []string `json:"zStreamTargets"`
+}
+
+// shippedZeroFloor are the x.y.0 targets of releases which shipped before the
+// org data listed releases. They are always in ShippedZeroTargets so bugs
+// targeting them are still found once a release is dropped from the org data.
+var shippedZeroFloor = []string{"4.1.0", "4.2.0", "4.3.0", "4.4.0"}
+
+// releaseVersion returns the x.y version for a release name like "4.7" or
+// "4.7.0" and a key which sorts versions numerically.
+func releaseVersion(name string) (version string, key int, ok bool) {
+	vs := strings.Split(name, ".")
+	if len(vs) < 2 {
+		return "", 0, false
+	}
+	major, err := strconv.Atoi(vs[0])
+	if err != nil {
+		return "", 0, false
+	}
+	minor, err := strconv.Atoi(vs[1])
+	if err != nil {
+		return "", 0, false
+	}
+	return fmt.Sprintf("%d.%d", major, minor), major*1000 + minor, true
+}
+
+// GA returns when the release went, or will go, generally available.
+func (r ReleaseInfo) GA() (time.Time, bool) {
+	if r.Milestones == nil || r.Milestones.GA == "" {
+		return time.Time{}, false
+	}
+	ga, err := time.Parse(milestoneDateFormat, r.Milestones.GA)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return ga, true
+}
+
+func (r ReleaseInfo) onlyZStream() bool {
+	for _, target := range r.Targets {
+		if !strings.HasSuffix(target, ".z") {
+			return false
+		}
+	}
+	return true
+}
+
+// Shipped is true if the release's GA milestone has passed or if the release
+// only accepts z-stream targets.
+func (r ReleaseInfo) Shipped(now time.Time) bool {
+	if ga, ok := r.GA(); ok {
+		return !now.Before(ga)
+	}
+	return r.onlyZStream()
+}
+
+// GetReleaseTargets works out the current, shipped and z-stream targets from
+// the releases and their milestones.
+func (orgData OrgData) GetReleaseTargets(now time.Time) (*ReleaseTargets, error) {
+	type release struct {
+		version string
+		key     int
+		info    ReleaseInfo
+	}
+	releases := []release{}
+	all := []string{}
+	for _, info := range orgData.Releases {
+		all = append(all, info.Name)
+		version, key, ok := releaseVersion(info.Name)
+		if !ok {
+			continue
+		}
+		releases = append(releases, release{version: version, key: key, info: info})
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].key < releases[j].key
+	})
+
+	rt := &ReleaseTargets{
+		CurrentTargets:     []string{},
+		Shipped:            []string{},
+		ShippedZeroTargets: append([]string{}, shippedZeroFloor...),
+		ZStreamTargets:     []string{},
+	}
+	floor := sets.NewString(shippedZeroFloor...)
+	for _, r := range releases {
+		for _, target := range r.info.Targets {
+			if strings.HasSuffix(target, ".z") {
+				rt.ZStreamTargets = append(rt.ZStreamTargets, target)
+			}
+		}
+		if r.info.Shipped(now) {
+			rt.Shipped = append(rt.Shipped, r.version)
+			if target := r.version + ".0"; !floor.Has(target) {
+				rt.ShippedZeroTargets = append(rt.ShippedZeroTargets, target)
+			}
+			continue
+		}
+		if rt.Current != "" {
+			continue
+		}
+		rt.Current = r.version
+		for _, target := range r.info.Targets {
+			if !strings.HasSuffix(target, ".z") {
+				rt.CurrentTargets = append(rt.CurrentTargets, target)
+			}
+		}
+	}
+	if rt.Current == "" {
+		return nil, fmt.Errorf("no release found that has not shipped: %v", all)
+	}
+	return rt, nil
+}
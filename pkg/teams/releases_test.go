@@ -0,0 +1,89 @@
+package teams
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetReleaseTargets(t *testing.T) {
+	now := time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		releases []ReleaseInfo
+		expected *ReleaseTargets
+	}{
+		{
+			name: "milestones",
+			releases: []ReleaseInfo{
+				{Name: "4.10", Targets: []string{"4.10.0"}, Milestones: &Milestones{GA: "2021-06-01"}},
+				{Name: "4.7", Targets: []string{"4.7.0", "4.7.z"}, Milestones: &Milestones{GA: "2021-01-15"}},
+				{Name: "4.6", Targets: []string{"4.6.0", "4.6.z"}, Milestones: &Milestones{GA: "2020-10-27"}},
+				{Name: "4.5", Targets: []string{"4.5.z"}},
+			},
+			expected: &ReleaseTargets{
+				Current:            "4.7",
+				CurrentTargets:     []string{"4.7.0"},
+				Shipped:            []string{"4.5", "4.6"},
+				ShippedZeroTargets: []string{"4.1.0", "4.2.0", "4.3.0", "4.4.0", "4.5.0", "4.6.0"},
+				ZStreamTargets:     []string{"4.5.z", "4.6.z", "4.7.z"},
+			},
+		},
+		{
+			name: "no milestones",
+			releases: []ReleaseInfo{
+				{Name: "4.6", Targets: []string{"4.6.z"}},
+				{Name: "4.7", Targets: []string{"4.7.0"}},
+			},
+			expected: &ReleaseTargets{
+				Current:            "4.7",
+				CurrentTargets:     []string{"4.7.0"},
+				Shipped:            []string{"4.6"},
+				ShippedZeroTargets: []string{"4.1.0", "4.2.0", "4.3.0", "4.4.0", "4.6.0"},
+				ZStreamTargets:     []string{"4.6.z"},
+			},
+		},
+		{
+			// releases dropped from the org data keep being found
+			name: "floor",
+			releases: []ReleaseInfo{
+				{Name: "4.4", Targets: []string{"4.4.z"}},
+				{Name: "4.7", Targets: []string{"4.7.0"}},
+			},
+			expected: &ReleaseTargets{
+				Current:            "4.7",
+				CurrentTargets:     []string{"4.7.0"},
+				Shipped:            []string{"4.4"},
+				ShippedZeroTargets: []string{"4.1.0", "4.2.0", "4.3.0", "4.4.0"},
+				ZStreamTargets:     []string{"4.4.z"},
+			},
+		},
+		{
+			name: "everything shipped",
+			releases: []ReleaseInfo{
+				{Name: "4.6", Targets: []string{"4.6.z"}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			orgData := OrgData{Releases: map[string]ReleaseInfo{}}
+			for _, r := range test.releases {
+				orgData.Releases[r.Name] = r
+			}
+			rt, err := orgData.GetReleaseTargets(now)
+			if test.expected == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got %#v", rt)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rt, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, rt)
+			}
+		})
+	}
+}
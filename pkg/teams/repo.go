@@ -0,0 +1,209 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-github/github"
+	"github.com/imdario/mergo"
+	"github.com/spf13/cobra"
+)
+
+const (
+	repoOwnerFlagName   = "org-data-repo-owner"
+	repoOwnerFlagDefVal = "openshift"
+
+	repoNameFlagName   = "org-data-repo"
+	repoNameFlagDefVal = "li"
+
+	repoRefFlagName   = "org-data-repo-ref"
+	repoRefFlagDefVal = ""
+
+	repoFilesFlagName = "org-data-files"
+
+	githubURLFlagName   = "github-base-url"
+	githubURLFlagDefVal = ""
+)
+
+var (
+	// shiftzilla_cfg.yaml has most of the data, but shiftzilla doesn't
+	// understand subcomponents. So subcomponent_teams.yaml overwrites it with
+	// the teams with subcomponents in bugzilla.
+	repoFilesFlagDefVal = []string{
+		"tools/shiftzilla/shiftzilla_cfg.yaml",
+		"tools/shiftzilla/subcomponent_teams.yaml",
+	}
+
+	// blobCaches are the blobs of every repository, and set of files in it,
+	// loaded. They are kept apart so loading one never evicts another's.
+	blobCaches = map[string]blobCache{}
+	blobsLock  sync.Mutex
+)
+
+// blobCache is the contents of the org data files by blob SHA, so files which
+// have not changed are not downloaded again
+type blobCache map[string][]byte
+
+// OrgDataRepo is where the org data lives in a GitHub repository.
+type OrgDataRepo struct {
+	Owner string
+	Repo  string
+	// Ref is the branch, tag or commit to read, the default branch if empty
+	Ref string
+	// Files are merged in order, later files overwrite earlier ones
+	Files []string
+}
+
+func (r OrgDataRepo) String() string {
+	s := fmt.Sprintf("%s/%s", r.Owner, r.Repo)
+	if r.Ref != "" {
+		s += "@" + r.Ref
+	}
+	return s
+}
+
+// cacheKey is the blobCache of r when loaded with client.
+func (r OrgDataRepo) cacheKey(client *github.Client) string {
+	return fmt.Sprintf("%s %s %v", client.BaseURL, r, r.Files)
+}
+
+// blobSHA looks up the SHA of the file at p by listing its directory, which
+// is cheaper than fetching the file.
+func (r OrgDataRepo) blobSHA(ctx context.Context, client *github.Client, p string) (string, error) {
+	dir := path.Dir(p)
+	if dir == "." {
+		// the root of the repository
+		dir = ""
+	}
+	_, entries, _, err := client.Repositories.GetContents(ctx, r.Owner, r.Repo, dir, &github.RepositoryContentGetOptions{Ref: r.Ref})
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.GetPath() == p {
+			return entry.GetSHA(), nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in %s", p, r)
+}
+
+// getFile returns the file at p, from cache if its blob is there.
+func (r OrgDataRepo) getFile(ctx context.Context, client *github.Client, cache blobCache, p string) (string, []byte, error) {
+	sha, err := r.blobSHA(ctx, client, p)
+	if err != nil {
+		return "", nil, err
+	}
+	if contents, ok := cache[sha]; ok {
+		return sha, contents, nil
+	}
+	contents, _, err := client.Git.GetBlobRaw(ctx, r.Owner, r.Repo, sha)
+	if err != nil {
+		return "", nil, err
+	}
+	return sha, contents, nil
+}
+
+// Load fetches and merges every file in the repository. Files are only
+// downloaded when their blob SHA changes.
+func (r OrgDataRepo) Load(ctx context.Context, client *github.Client) (*OrgData, error) {
+	if len(r.Files) == 0 {
+		return nil, fmt.Errorf("no org data files given for %s", r)
+	}
+	key := r.cacheKey(client)
+	blobsLock.Lock()
+	cache := blobCaches[key]
+	blobsLock.Unlock()
+
+	var orgData *OrgData
+	// only the blobs loaded now are kept, which forgets old versions of the
+	// files
+	loaded := blobCache{}
+	for _, p := range r.Files {
+		sha, contents, err := r.getFile(ctx, client, cache, p)
+		if err != nil {
+			return nil, err
+		}
+		loaded[sha] = contents
+
+		teamData := DiskOrgData{}
+		if err := yaml.Unmarshal(contents, &teamData); err != nil {
+			return nil, fmt.Errorf("unable to parse %s in %s: %v", p, r, err)
+		}
+		fileOrgData, err := diskDataToOrgData(teamData)
+		if err != nil {
+			return nil, err
+		}
+		if orgData == nil {
+			orgData = fileOrgData
+			continue
+		}
+		if err := mergo.MergeWithOverwrite(orgData, fileOrgData); err != nil {
+			return nil, err
+		}
+	}
+
+	blobsLock.Lock()
+	blobCaches[key] = loaded
+	blobsLock.Unlock()
+	return orgData, nil
+}
+
+// GetOrgDataRepo returns the repository chosen by the --org-data-repo flags.
+func GetOrgDataRepo(cmd *cobra.Command) (OrgDataRepo, error) {
+	r := OrgDataRepo{}
+	var err error
+	if r.Owner, err = cmd.Flags().GetString(repoOwnerFlagName); err != nil {
+		return r, err
+	}
+	if r.Repo, err = cmd.Flags().GetString(repoNameFlagName); err != nil {
+		return r, err
+	}
+	if r.Ref, err = cmd.Flags().GetString(repoRefFlagName); err != nil {
+		return r, err
+	}
+	if r.Files, err = cmd.Flags().GetStringSlice(repoFilesFlagName); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// GetGithubClient returns a client for github.com, or the GitHub Enterprise
+// API at --github-base-url, authenticated with --github-key.
+func GetGithubClient(ctx context.Context, cmd *cobra.Command) (*github.Client, error) {
+	baseURL, err := cmd.Flags().GetString(githubURLFlagName)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := GetGithubAuthClient(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if baseURL == "" {
+		return github.NewClient(transport), nil
+	}
+	return github.NewEnterpriseClient(baseURL, baseURL, transport)
+}
+
+func getOrgDataFromRepo(cmd *cobra.Command) (*OrgData, error) {
+	ctx := context.Background()
+	r, err := GetOrgDataRepo(cmd)
+	if err != nil {
+		return nil, err
+	}
+	client, err := GetGithubClient(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	return r.Load(ctx, client)
+}
+
+func addRepoFlags(cmd *cobra.Command) {
+	cmd.Flags().String(repoOwnerFlagName, repoOwnerFlagDefVal, "Owner of the GitHub repository with the org data")
+	cmd.Flags().String(repoNameFlagName, repoNameFlagDefVal, "GitHub repository with the org data")
+	cmd.Flags().String(repoRefFlagName, repoRefFlagDefVal, "Branch, tag or commit of the org data repository to read, the default branch if empty")
+	cmd.Flags().StringSlice(repoFilesFlagName, repoFilesFlagDefVal, "Org data files in the repository, merged in order with later files overwriting earlier ones")
+	cmd.Flags().String(githubURLFlagName, githubURLFlagDefVal, "GitHub Enterprise API URL, like https://github.example.com/api/v3/, empty for github.com")
+}
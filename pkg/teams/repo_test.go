@@ -0,0 +1,84 @@
+package teams
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestOrgDataRepoLoad(t *testing.T) {
+	files := map[string]string{
+		"sha-main": "Teams:\n- name: Node\n  components: [Node]\n  slack_chan: '#node'\n- name: Storage\n  components: [Storage]\n",
+		"sha-sub":  "Teams:\n- name: Node\n  components: [Node]\n  subcomponents:\n    Node: [Kubelet]\n",
+		"sha-root": "Teams:\n- name: Etcd\n  components: [Etcd]\n",
+	}
+	blobFetches := map[string]int{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/org/config/contents/teams", func(w http.ResponseWriter, r *http.Request) {
+		if ref := r.URL.Query().Get("ref"); ref != "stable" {
+			t.Errorf("expected ref stable, got %q", ref)
+		}
+		json.NewEncoder(w).Encode([]map[string]string{
+			{"type": "file", "path": "teams/main.yaml", "sha": "sha-main"},
+			{"type": "file", "path": "teams/subcomponents.yaml", "sha": "sha-sub"},
+		})
+	})
+	mux.HandleFunc("/api/v3/repos/org/other/contents/", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]string{
+			{"type": "file", "path": "teams.yaml", "sha": "sha-root"},
+		})
+	})
+	for _, repo := range []string{"config", "other"} {
+		prefix := "/api/v3/repos/org/" + repo + "/git/blobs/"
+		mux.HandleFunc(prefix, func(w http.ResponseWriter, r *http.Request) {
+			sha := r.URL.Path[len(prefix):]
+			blobFetches[sha]++
+			w.Write([]byte(files[sha]))
+		})
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := github.NewEnterpriseClient(server.URL+"/api/v3/", server.URL+"/api/v3/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := OrgDataRepo{Owner: "org", Repo: "config", Ref: "stable", Files: []string{"teams/main.yaml", "teams/subcomponents.yaml"}}
+	// a file in the root of another repository, loading it must not evict
+	// the blobs of the first
+	other := OrgDataRepo{Owner: "org", Repo: "other", Files: []string{"teams.yaml"}}
+	for i := 0; i < 2; i++ {
+		orgData, err := r.Load(context.Background(), client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := TeamInfo{Name: "Node", Components: []string{"Node"}, Subcomponents: map[string][]string{"Node": {"Kubelet"}}, SlackChan: "#node"}
+		if node := orgData.Teams["Node"]; !reflect.DeepEqual(node, expected) {
+			t.Errorf("expected %+v got %+v", expected, node)
+		}
+		if _, ok := orgData.Teams["Storage"]; !ok {
+			t.Errorf("expected Storage from the first file, got %v", orgData.GetTeamNames())
+		}
+
+		orgData, err = other.Load(context.Background(), client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := orgData.Teams["Etcd"]; !ok {
+			t.Errorf("expected Etcd from the root file, got %v", orgData.GetTeamNames())
+		}
+	}
+	if !reflect.DeepEqual(blobFetches, map[string]int{"sha-main": 1, "sha-sub": 1, "sha-root": 1}) {
+		t.Errorf("expected each blob to be fetched once, got %v", blobFetches)
+	}
+
+	r.Files = []string{"teams/missing.yaml"}
+	if _, err := r.Load(context.Background(), client); err == nil {
+		t.Errorf("expected a missing file to fail")
+	}
+}
@@ -2,30 +2,20 @@ package teams
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"sort"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/openshift/bugzilla-tools/pkg/config"
 
 	"github.com/eparis/bugzilla"
-	"github.com/ghodss/yaml"
-	"github.com/google/go-github/github"
 	"github.com/imdario/mergo"
-	"github.com/kr/pretty"
 	"github.com/spf13/cobra"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-	"google.golang.org/api/sheets/v4"
 )
 
 const (
@@ -44,11 +34,14 @@ const (
 	teamOverwriteFlagName   = "overwrite-team-data"
 	teamOverwriteFlagDefVal = ""
 
-	gsheetKeyFlagName   = "google-sheet"
-	gsheetKeyFlagDefVal = "./"
-
 	orgDataURLFlagName   = "org-data-url"
 	orgDataURLFlagDefVal = "http://team-exportor/teams"
+
+	maxAgeFlagName   = "org-data-max-age"
+	maxAgeFlagDefVal = time.Hour
+
+	// reconcilePeriod is how often Reconciler loads the org data
+	reconcilePeriod = time.Minute * 5
 )
 
 func isForTeam(team TeamInfo, componentToFind string, subcomponentToFind string) (isTeam, isDef bool) {
@@ -80,32 +73,24 @@ func isForTeam(team TeamInfo, componentToFind string, subcomponentToFind string)
 	return false, false
 }
 
+// GetTeamByComponent returns the team owning the component and subcomponent,
+// see ResolveOwner for how a team is chosen when several match.
 func (orgData OrgData) GetTeamByComponent(component, subcomponent string) *TeamInfo {
-	var defTeam *TeamInfo
-	for i := range orgData.Teams {
-		team := orgData.Teams[i]
-		yes, isDef := isForTeam(team, component, subcomponent)
-		if yes {
-			return &team
-		}
-		if isDef {
-			defTeam = &team
-		}
+	bug := &bugzilla.Bug{Component: []string{component}}
+	if subcomponent != "" {
+		bug.SubComponent = map[string][]string{component: {subcomponent}}
+	}
+	owner := orgData.ResolveOwner(bug)
+	if owner.Winner == nil {
+		return nil
 	}
-	return defTeam
+	team := orgData.Teams[owner.Team]
+	return &team
 }
 
+// GetTeamName returns the team owning the bug, "unknown" if there is none.
 func (orgData OrgData) GetTeamName(bug *bugzilla.Bug) string {
-	component := bug.Component[0]
-	subcomponent := ""
-	if subcomponents, ok := bug.SubComponent[component]; ok {
-		subcomponent = subcomponents[0]
-	}
-	teamInfo := orgData.GetTeamByComponent(component, subcomponent)
-	if teamInfo == nil {
-		return "unknown"
-	}
-	return teamInfo.Name
+	return orgData.ResolveOwner(bug).Team
 }
 
 func (orgData OrgData) GetTeamNames() []string {
@@ -151,36 +136,6 @@ func GetGithubAuthClient(ctx context.Context, cmd *cobra.Command) (*http.Client,
 	return tc, nil
 }
 
-func getOrgDataFromLiPath(cmd *cobra.Command, path string) (*OrgData, error) {
-	ctx := context.Background()
-	transport, err := GetGithubAuthClient(ctx, cmd)
-	if err != nil {
-		return nil, err
-	}
-	client := github.NewClient(transport)
-	file, _, _, err := client.Repositories.GetContents(ctx, "openshift", "li", path, nil)
-	if err != nil {
-		return nil, err
-	}
-	encoded := *file.Content
-	contents, err := base64.StdEncoding.DecodeString(encoded)
-	if err != nil {
-		return nil, err
-	}
-
-	teamData := DiskOrgData{}
-	err = yaml.Unmarshal(contents, &teamData)
-	if err != nil {
-		return nil, err
-	}
-
-	orgData, err := diskDataToOrgData(teamData)
-	if err != nil {
-		return nil, err
-	}
-	return orgData, nil
-}
-
 func getOrgDataFromFile(cmd *cobra.Command, whichFlag string) (*OrgData, error) {
 	ctx := context.Background()
 	teamData := DiskOrgData{}
@@ -196,142 +151,6 @@ func getOrgDataFromFile(cmd *cobra.Command, whichFlag string) (*OrgData, error)
 	return orgData, nil
 }
 
-// Retrieve a token, saves the token, then returns the generated client.
-func getClient(dir string, config *oauth2.Config) *http.Client {
-	// The file token.json stores the user's access and refresh tokens, and is
-	// created automatically when the authorization flow completes for the first
-	// time.
-	tokFile := filepath.Join(dir, "token.json")
-	tok, err := tokenFromFile(tokFile)
-	if err != nil {
-		tok = getTokenFromWeb(config)
-		saveToken(tokFile, tok)
-	}
-	return config.Client(context.Background(), tok)
-}
-
-// Request a token from the web, then returns the retrieved token.
-func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf("Go to the following link in your browser then type the "+
-		"authorization code: \n%v\n", authURL)
-
-	var authCode string
-	if _, err := fmt.Scan(&authCode); err != nil {
-		log.Fatalf("Unable to read authorization code: %v", err)
-	}
-
-	tok, err := config.Exchange(context.TODO(), authCode)
-	if err != nil {
-		log.Fatalf("Unable to retrieve token from web: %v", err)
-	}
-	return tok
-}
-
-// Retrieves a token from a local file.
-func tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	tok := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(tok)
-	return tok, err
-}
-
-// Saves a token to a file path.
-func saveToken(path string, token *oauth2.Token) {
-	fmt.Printf("Saving credential file to: %s\n", path)
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
-	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
-}
-
-func getTeamSizeFromGoogleDoc(cmd *cobra.Command, orgData *OrgData) error {
-	dir, err := cmd.Flags().GetString(gsheetKeyFlagName)
-	if err != nil {
-		return err
-	}
-	filename := filepath.Join(dir, "config.json")
-	b, err := ioutil.ReadFile(filename)
-	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
-	}
-
-	// If modifying these scopes, delete your previously saved token.json.
-	config, err := google.ConfigFromJSON(b, "https://www.googleapis.com/auth/spreadsheets.readonly")
-	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
-	}
-	client := getClient(dir, config)
-
-	service, err := sheets.New(client)
-	if err != nil {
-		return err
-	}
-	readRange := `'OCP Team Structure'!B:C`
-	resp, err := service.Spreadsheets.Values.Get("1M4C41fX2J1nBXhqPdtwd8UP4RAx98NA4ByIUv-0Z0Ds", readRange).Do()
-	if err != nil {
-		return err
-	}
-
-	if len(resp.Values) == 0 {
-		return fmt.Errorf("No data found in google sheet")
-	}
-
-	data := map[string]int{}
-	for _, row := range resp.Values {
-		if len(row) != 2 {
-			continue
-		}
-		team := row[0].(string)
-		sizeStr := row[1].(string)
-		size, err := strconv.ParseFloat(sizeStr, 32)
-		if err != nil {
-			continue
-		}
-		data[team] = int(size)
-	}
-
-	for team, count := range data {
-		if teamInfo, ok := orgData.Teams[team]; ok {
-			teamInfo.MemberCount = count
-			orgData.Teams[team] = teamInfo
-		} else {
-			if team == "" {
-				continue
-			}
-			pretty.Printf("Team %q: Found in Team Member Tracking Google Doc but not found in shiftzilla team config.\n", team)
-		}
-	}
-
-	return nil
-}
-
-// This fetches org data from the github.com/openshift/li/tools/shiftzilla repo. That repo has most data
-// inside shiftzilla_cfg.yaml, but shiftzilla doesn't understand subcomponents. So we have a second set
-// of data which overwrites the first set and includes information about teams with subcomponents in bugzilla.
-func getOrgDataFromLi(cmd *cobra.Command) (*OrgData, error) {
-	primaryOrgData, err := getOrgDataFromLiPath(cmd, "tools/shiftzilla/shiftzilla_cfg.yaml")
-	if err != nil {
-		return nil, err
-	}
-
-	secondaryOrgData, err := getOrgDataFromLiPath(cmd, "tools/shiftzilla/subcomponent_teams.yaml")
-	if err != nil {
-		return nil, err
-	}
-
-	if err = mergo.MergeWithOverwrite(primaryOrgData, secondaryOrgData); err != nil {
-		return nil, err
-	}
-	return primaryOrgData, nil
-}
-
 // This could actually get the data from local file (--test-team-data=) or from github itself.
 func getOrgDataFromGithub(cmd *cobra.Command) (*OrgData, error) {
 	orgData, err := getOrgDataFromFile(cmd, teamDataFlagName)
@@ -340,7 +159,7 @@ func getOrgDataFromGithub(cmd *cobra.Command) (*OrgData, error) {
 		return nil, err
 	} else if err == config.NotSetError {
 		// if the error was that the flag wasn't set pull from github
-		orgData, err = getOrgDataFromLi(cmd)
+		orgData, err = getOrgDataFromRepo(cmd)
 		if err != nil {
 			return nil, err
 		}
@@ -357,7 +176,7 @@ func getOrgDataFromGithub(cmd *cobra.Command) (*OrgData, error) {
 		}
 	}
 
-	err = getTeamSizeFromGoogleDoc(cmd, orgData)
+	err = applyMemberCounts(cmd, orgData)
 	if err != nil {
 		return nil, err
 	}
@@ -400,16 +219,19 @@ func getOrgDataFromService(cmd *cobra.Command) (*OrgData, error) {
 	if res.Body != nil {
 		defer res.Body.Close()
 	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to load org data from %s: %s", url, res.Status)
+	}
 
 	body, readErr := ioutil.ReadAll(res.Body)
 	if readErr != nil {
-		return nil, err
+		return nil, readErr
 	}
 
 	orgData := &OrgData{}
 	jsonErr := json.Unmarshal(body, orgData)
 	if jsonErr != nil {
-		return nil, err
+		return nil, jsonErr
 	}
 	orgData.cmd = cmd
 
@@ -430,61 +252,123 @@ func getOrgData(cmd *cobra.Command) (*OrgData, error) {
 	return orgData, err
 }
 
+// GetOrgData loads the org data. Failing here is fatal for most commands, as
+// unlike Reconcile there is no earlier org data to fall back on.
 func GetOrgData(cmd *cobra.Command) (*OrgData, error) {
-	return getOrgData(cmd)
+	maxAge, err := cmd.Flags().GetDuration(maxAgeFlagName)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	orgData, err := getOrgData(cmd)
+	if err != nil {
+		return nil, err
+	}
+	orgData.status = newLoadStatus(maxAge, start)
+	orgData.lock = &orgDataLock{}
+	return orgData, nil
 }
 
-// CurrentVersion returns the lowest x.y version that has a x.y.0 target.
+// orgDataLock keeps Reconcile from replacing the org data while it is read
+type orgDataLock struct {
+	// reconcile makes sure only one Reconcile runs at a time
+	reconcile sync.Mutex
+	// data protects the fields Reconcile replaces
+	data sync.RWMutex
+}
+
+// Current returns a copy of the org data which a Reconcile running at the
+// same time does not change. Anything reading org data which is reconciled
+// in the background, by Reconciler or by a controller, must read it through
+// Current and use the one copy for everything it does with it.
+func (orgData *OrgData) Current() OrgData {
+	if orgData.lock == nil {
+		return *orgData
+	}
+	orgData.lock.data.RLock()
+	defer orgData.lock.data.RUnlock()
+	return *orgData
+}
+
+// CurrentVersion returns the lowest x.y version which has not shipped.
 func (orgData OrgData) CurrentVersion() (string, error) {
-	var all []string
-	var active []string
-	var order []string
-	for _, v := range orgData.Releases {
-		all = append(all, v.Name)
-		onlyZ := true
-		for _, target := range v.Targets {
-			if !strings.HasSuffix(target, ".z") {
-				onlyZ = false
-				break
-			}
-		}
-		if onlyZ {
-			continue
-		}
+	rt, err := orgData.GetReleaseTargets(time.Now())
+	if err != nil {
+		return "", err
+	}
+	return rt.Current, nil
+}
 
-		vs := strings.Split(v.Name, ".")
-		if len(vs) < 2 {
-			continue
-		}
-		active = append(active, fmt.Sprintf("%s.%s", vs[0], vs[1]))
-		if len(vs[1]) == 1 {
-			vs[1] = "0" + vs[1]
-		}
-		order = append(order, vs[0]+vs[1])
+// OnChange calls f with the org data every time Reconcile changes it.
+func (orgData *OrgData) OnChange(f func(*OrgData)) {
+	orgData.onChange = append(orgData.onChange, f)
+}
+
+// Equal is true if both have the same teams, releases and SLOs
+func (orgData *OrgData) Equal(other *OrgData) bool {
+	a, errA := json.Marshal(orgData)
+	b, errB := json.Marshal(other)
+	return errA == nil && errB == nil && string(a) == string(b)
+}
+
+// Reconcile loads the org data again. If that fails the org data is left as
+// it was, so callers keep working with the last good data. Only one Reconcile
+// runs at a time, anything reading the org data meanwhile should use Current.
+func (orgData *OrgData) Reconcile() error {
+	if orgData.cmd == nil {
+		return fmt.Errorf("org data was not loaded from the command line and can not be reloaded")
 	}
-	if len(active) == 0 {
-		return "", fmt.Errorf("no release found that has a x.y.0 target version: %v", all)
+	if orgData.lock == nil {
+		return fmt.Errorf("org data was not loaded by GetOrgData and can not be reloaded")
 	}
-	sort.Slice(active, func(i, j int) bool {
-		return order[i] < order[j]
-	})
-	return active[0], nil
-}
+	orgData.lock.reconcile.Lock()
+	defer orgData.lock.reconcile.Unlock()
 
-func (orgData *OrgData) Reconcile() {
+	start := time.Now()
 	newOrgData, err := getOrgData(orgData.cmd)
+	reconcileDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
-		log.Fatalln(err)
+		reconcileErrors.Inc()
+		orgData.status.failed(start, err)
+		return err
+	}
+	orgData.status.loaded(start)
+	// only Reconcile changes the org data, so it can be read unlocked here
+	changed := !orgData.Equal(newOrgData)
+	orgData.lock.data.Lock()
+	orgData.OrgTitle = newOrgData.OrgTitle
+	orgData.Teams = newOrgData.Teams
+	orgData.Releases = newOrgData.Releases
+	orgData.SLO = newOrgData.SLO
+	orgData.sheetOnlyTeams = newOrgData.sheetOnlyTeams
+	orgData.sheetChecked = newOrgData.sheetChecked
+	orgData.lock.data.Unlock()
+	if changed {
+		for _, f := range orgData.onChange {
+			f(orgData)
+		}
 	}
-	*orgData = *newOrgData
+	return nil
 }
 
+// Reconciler reloads the org data every reconcilePeriod in the background.
+// Failures keep the last good data and retry sooner, backing off up to
+// reconcilePeriod.
 func (orgData *OrgData) Reconciler() {
 	go func() {
+		backoff := reconcileBackoff
 		for true {
-			orgData.Reconcile()
-			fmt.Printf("Successfully fetched OrgData len(teams):%d len(releases): %d\n", len(orgData.Teams), len(orgData.Releases))
-			time.Sleep(time.Minute * 5)
+			if err := orgData.Reconcile(); err != nil {
+				delay := backoff.Step()
+				h := orgData.Health(time.Now())
+				fmt.Printf("Unable to fetch OrgData, using data from %s ago, retrying in %v: %v\n", h.Age, delay.Round(time.Second), err)
+				time.Sleep(delay)
+				continue
+			}
+			backoff = reconcileBackoff
+			current := orgData.Current()
+			fmt.Printf("Successfully fetched OrgData len(teams):%d len(releases): %d\n", len(current.Teams), len(current.Releases))
+			time.Sleep(reconcilePeriod)
 		}
 	}()
 }
@@ -494,6 +378,8 @@ func AddFlags(cmd *cobra.Command) {
 	cmd.Flags().String(githubKeyFlagName, githubKeyFlagDefVal, "Path to file containing github key")
 	cmd.Flags().String(teamDataFlagName, teamDataFlagDefVal, "Path to file containing team data")
 	cmd.Flags().String(teamOverwriteFlagName, teamOverwriteFlagDefVal, "Path to file containing team data to overwrite with github/file data")
-	cmd.Flags().String(gsheetKeyFlagName, gsheetKeyFlagDefVal, "Path to file containing google sheets oauth keys")
+	addRepoFlags(cmd)
+	addMemberCountFlags(cmd)
 	cmd.Flags().String(orgDataURLFlagName, orgDataURLFlagDefVal, "URL to Load Org Data, http://localhost:8000/teams might be your choice locally")
+	cmd.Flags().Duration(maxAgeFlagName, maxAgeFlagDefVal, "How old the org data may get, while loading it fails, before /orgdata/health reports it stale")
 }
@@ -21,6 +21,18 @@ type TeamInfo struct {
 	Subcomponents map[string][]string    `json:"subcomponents,omitempty"`
 	MemberCount   int                    `json:"memberCount,omitempty"`
 	SLO           map[string]sloAPI.Data `json:"slo,omitempty"`
+	Lifecycle     *LifecycleConfig       `json:"lifecycle,omitempty"`
+}
+
+// LifecycleConfig overrides when a team's bugs are marked LifecycleStale and
+// when stale bugs are closed. Unset values use the command line defaults.
+type LifecycleConfig struct {
+	// Disabled stops the lifecycle controller from touching the team's bugs
+	Disabled bool `json:"disabled,omitempty"`
+	// StaleAfterDays without any change marks a bug stale
+	StaleAfterDays int `json:"staleAfterDays,omitempty"`
+	// CloseAfterDays after being marked stale, without any other change, a bug is closed
+	CloseAfterDays int `json:"closeAfterDays,omitempty"`
 }
 
 type Milestones struct {
@@ -49,4 +61,15 @@ type OrgData struct {
 	Releases map[string]ReleaseInfo `json:"releases,omitempty"`
 	SLO      map[string]sloAPI.Data `json:"slo,omitempty"`
 	cmd      *cobra.Command
+	// teams in the member count sheet but not in the org data
+	sheetOnlyTeams []string
+	// sheetChecked is true if the member counts came from a sheet or file
+	// and were compared with the teams
+	sheetChecked bool
+	// called when Reconcile changes the org data
+	onChange []func(*OrgData)
+	// when the org data was loaded, shared across reconciles
+	status *loadStatus
+	// lock is shared across reconciles like status, see Current
+	lock *orgDataLock
 }
@@ -0,0 +1,224 @@
+package teams
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eparis/bugzilla"
+)
+
+// The kinds of problem Validate finds
+const (
+	ComponentConflict = "ComponentConflict"
+	DefaultConflict   = "DefaultConflict"
+	NoComponents      = "NoComponents"
+	NoSlackChannel    = "NoSlackChannel"
+	NoLead            = "NoLead"
+	UnknownSheetTeam  = "UnknownSheetTeam"
+	BadRelease        = "BadRelease"
+	UnownedComponent  = "UnownedComponent"
+)
+
+// Problem is something wrong with the org data.
+type Problem struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Kind, p.Message)
+}
+
+func problemf(kind, format string, args ...interface{}) Problem {
+	return Problem{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// componentClaims is every team claiming a component
+type componentClaims struct {
+	// teams owning every subcomponent
+	whole []string
+	// teams which are !!DEFAULT!!
+	def []string
+	// teams listing each subcomponent
+	subcomponents map[string][]string
+}
+
+func (orgData OrgData) componentClaims() map[string]*componentClaims {
+	out := map[string]*componentClaims{}
+	for _, name := range orgData.GetTeamNames() {
+		team := orgData.Teams[name]
+		for _, component := range team.Components {
+			claims, ok := out[component]
+			if !ok {
+				claims = &componentClaims{subcomponents: map[string][]string{}}
+				out[component] = claims
+			}
+			subcomponents, ok := team.Subcomponents[component]
+			switch {
+			case !ok:
+				claims.whole = append(claims.whole, name)
+			case len(subcomponents) == 1 && subcomponents[0] == defaultForSubcomponentsTag:
+				claims.def = append(claims.def, name)
+			default:
+				for _, subcomponent := range subcomponents {
+					claims.subcomponents[subcomponent] = append(claims.subcomponents[subcomponent], name)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string][]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (orgData OrgData) validateComponents() []Problem {
+	problems := []Problem{}
+	all := orgData.componentClaims()
+	components := make([]string, 0, len(all))
+	for component := range all {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	for _, component := range components {
+		claims := all[component]
+		if len(claims.whole) > 1 {
+			problems = append(problems, problemf(ComponentConflict, "component %s is owned by %s", component, strings.Join(claims.whole, ", ")))
+		}
+		for _, subcomponent := range sortedKeys(claims.subcomponents) {
+			teams := claims.subcomponents[subcomponent]
+			if subcomponent == defaultForSubcomponentsTag {
+				problems = append(problems, problemf(DefaultConflict, "%s must be the only subcomponent of %s listed by %s", defaultForSubcomponentsTag, component, strings.Join(teams, ", ")))
+				continue
+			}
+			if len(teams) > 1 {
+				problems = append(problems, problemf(ComponentConflict, "subcomponent %s/%s is listed by %s", component, subcomponent, strings.Join(teams, ", ")))
+			}
+		}
+		if len(claims.def) > 1 {
+			problems = append(problems, problemf(DefaultConflict, "component %s has several %s teams: %s", component, defaultForSubcomponentsTag, strings.Join(claims.def, ", ")))
+		}
+		if len(claims.def) > 0 && len(claims.whole) > 0 {
+			problems = append(problems, problemf(DefaultConflict, "%s is %s for %s but %s owns all of it, so it never gets a bug", strings.Join(claims.def, ", "), defaultForSubcomponentsTag, component, strings.Join(claims.whole, ", ")))
+		}
+	}
+	return problems
+}
+
+func (orgData OrgData) validateTeams() []Problem {
+	problems := []Problem{}
+	for _, name := range orgData.GetTeamNames() {
+		team := orgData.Teams[name]
+		if len(team.Components) == 0 {
+			problems = append(problems, problemf(NoComponents, "team %s has no components", name))
+		}
+		if team.SlackChan == "" {
+			problems = append(problems, problemf(NoSlackChannel, "team %s has no slack channel", name))
+		}
+		if team.Lead == "" {
+			problems = append(problems, problemf(NoLead, "team %s has no lead", name))
+		}
+	}
+	for _, team := range orgData.sheetOnlyTeams {
+		problems = append(problems, problemf(UnknownSheetTeam, "team %s is in the member count sheet but not in the org data", team))
+	}
+	return problems
+}
+
+// SheetChecked is true if the teams were compared with the member count sheet,
+// so Validate reports UnknownSheetTeam problems. They are only compared when
+// the org data is loaded with --data-from-github and the member counts do not
+// come from the org data itself.
+func (orgData *OrgData) SheetChecked() bool {
+	return orgData.sheetChecked
+}
+
+func (orgData OrgData) validateReleases() []Problem {
+	problems := []Problem{}
+	names := make([]string, 0, len(orgData.Releases))
+	for name := range orgData.Releases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		release := orgData.Releases[name]
+		if _, _, ok := releaseVersion(name); !ok {
+			problems = append(problems, problemf(BadRelease, "release %q is not named x.y", name))
+		}
+		if release.Milestones == nil {
+			continue
+		}
+		milestones := []struct {
+			name  string
+			value string
+		}{
+			{"start", release.Milestones.Start},
+			{"feature_complete", release.Milestones.FeatureComplete},
+			{"code_freeze", release.Milestones.CodeFreeze},
+			{"ga", release.Milestones.GA},
+		}
+		for _, milestone := range milestones {
+			if milestone.value == "" {
+				continue
+			}
+			if _, err := time.Parse(milestoneDateFormat, milestone.value); err != nil {
+				problems = append(problems, problemf(BadRelease, "release %s milestone %s %q is not %s", name, milestone.name, milestone.value, milestoneDateFormat))
+			}
+		}
+	}
+	return problems
+}
+
+// Validate checks the org data for components claimed by several teams,
+// conflicting !!DEFAULT!! teams, teams without components, slack channels or
+// leads, teams only in the member count sheet and releases with bad names or
+// milestones.
+func (orgData OrgData) Validate() []Problem {
+	problems := orgData.validateComponents()
+	problems = append(problems, orgData.validateTeams()...)
+	problems = append(problems, orgData.validateReleases()...)
+	return problems
+}
+
+// ValidateBugComponents returns a problem for every component and
+// subcomponent of the bugs which no team owns.
+func (orgData OrgData) ValidateBugComponents(bugs []*bugzilla.Bug) []Problem {
+	unowned := map[string][]int{}
+	for _, bug := range bugs {
+		for _, component := range bug.Component {
+			subcomponents := bug.SubComponent[component]
+			if len(subcomponents) == 0 {
+				subcomponents = []string{""}
+			}
+			for _, subcomponent := range subcomponents {
+				if orgData.GetTeamByComponent(component, subcomponent) != nil {
+					continue
+				}
+				key := component
+				if subcomponent != "" {
+					key += "/" + subcomponent
+				}
+				unowned[key] = append(unowned[key], bug.ID)
+			}
+		}
+	}
+	problems := []Problem{}
+	keys := make([]string, 0, len(unowned))
+	for key := range unowned {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		problems = append(problems, problemf(UnownedComponent, "no team owns %s, which has %d open bugs", key, len(unowned[key])))
+	}
+	return problems
+}
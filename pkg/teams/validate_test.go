@@ -0,0 +1,54 @@
+package teams
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eparis/bugzilla"
+)
+
+func TestValidate(t *testing.T) {
+	orgData := OrgData{
+		Teams: map[string]TeamInfo{
+			"Good":     {Name: "Good", Components: []string{"Good"}, SlackChan: "#good", Lead: "lead@example.com"},
+			"Empty":    {Name: "Empty", SlackChan: "#empty", Lead: "lead@example.com"},
+			"Logging":  {Name: "Logging", Components: []string{"Logging"}, SlackChan: "#logging", Lead: "lead@example.com"},
+			"Metering": {Name: "Metering", Components: []string{"Logging"}, Subcomponents: map[string][]string{"Logging": {defaultForSubcomponentsTag}}, SlackChan: "#metering", Lead: "lead@example.com"},
+			"Node":     {Name: "Node", Components: []string{"Node"}, Subcomponents: map[string][]string{"Node": {"Kubelet"}}},
+			"NodeToo":  {Name: "NodeToo", Components: []string{"Node"}, Subcomponents: map[string][]string{"Node": {"Kubelet", defaultForSubcomponentsTag}}, SlackChan: "#node", Lead: "lead@example.com"},
+		},
+		Releases: map[string]ReleaseInfo{
+			"4.7":  {Name: "4.7", Milestones: &Milestones{GA: "2021-01-15"}},
+			"next": {Name: "next", Milestones: &Milestones{CodeFreeze: "Jan 5"}},
+		},
+		sheetOnlyTeams: []string{"Sheet"},
+	}
+	expected := []Problem{
+		{DefaultConflict, "Metering is !!DEFAULT!! for Logging but Logging owns all of it, so it never gets a bug"},
+		{DefaultConflict, "!!DEFAULT!! must be the only subcomponent of Node listed by NodeToo"},
+		{ComponentConflict, "subcomponent Node/Kubelet is listed by Node, NodeToo"},
+		{NoComponents, "team Empty has no components"},
+		{NoSlackChannel, "team Node has no slack channel"},
+		{NoLead, "team Node has no lead"},
+		{UnknownSheetTeam, "team Sheet is in the member count sheet but not in the org data"},
+		{BadRelease, `release "next" is not named x.y`},
+		{BadRelease, `release next milestone code_freeze "Jan 5" is not 2006-01-02`},
+	}
+	if problems := orgData.Validate(); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("expected\n%v\ngot\n%v", expected, problems)
+	}
+
+	bugs := []*bugzilla.Bug{
+		{ID: 1, Component: []string{"Good"}},
+		{ID: 2, Component: []string{"Documentation"}},
+		{ID: 3, Component: []string{"Node"}, SubComponent: map[string][]string{"Node": {"CRI-O"}}},
+		{ID: 4, Component: []string{"Documentation"}},
+	}
+	expected = []Problem{
+		{UnownedComponent, "no team owns Documentation, which has 2 open bugs"},
+		{UnownedComponent, "no team owns Node/CRI-O, which has 1 open bugs"},
+	}
+	if problems := orgData.ValidateBugComponents(bugs); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("expected\n%v\ngot\n%v", expected, problems)
+	}
+}